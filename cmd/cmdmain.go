@@ -5,11 +5,15 @@ import (
 	"path/filepath"
 
 	"github.com/wenwenxiong/network-pinger/cmd/pinger"
+	"github.com/wenwenxiong/network-pinger/cmd/preflight"
+	"github.com/wenwenxiong/network-pinger/cmd/receiver"
 	"github.com/wenwenxiong/network-pinger/pkg/util"
 )
 
 const (
 	CmdPinger                = "network-pinger"
+	CmdWebhookReceiver       = "network-pinger-webhook-receiver"
+	CmdPreflight             = "network-pinger-preflight"
 )
 
 func main() {
@@ -17,6 +21,10 @@ func main() {
 	switch cmd {
 	case CmdPinger:
 		pinger.CmdMain()
+	case CmdWebhookReceiver:
+		receiver.CmdMain()
+	case CmdPreflight:
+		preflight.CmdMain()
 	default:
 		util.LogFatalAndExit(nil, "%s is an unknown command", cmd)
 	}
@@ -1,9 +1,14 @@
 package pinger
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof" // #nosec
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -24,19 +29,44 @@ func CmdMain() {
 	if err != nil {
 		util.LogFatalAndExit(err, "failed to parse config")
 	}
+
+	// SIGTERM cancels the probes in flight, the pinger then flushes its results and returns
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	var server *http.Server
 	if config.Mode == "server" && config.EnableMetrics {
 		http.Handle("/metrics", promhttp.Handler())
+		http.Handle("/api/v1/results", config.ResultsHandler())
+		http.Handle("/api/v1/history", config.HistoryHandler())
+		http.Handle("/api/v1/outages", config.OutagesHandler())
+		http.Handle("/api/v1/cluster", config.ClusterHandler())
+		http.Handle("/dashboard/", pinger.DashboardHandler())
+		http.Handle("/dashboard", http.RedirectHandler("/dashboard/", http.StatusMovedPermanently))
 
+		// conform to Gosec G114
+		// https://github.com/securego/gosec#available-rules
+		server = &http.Server{
+			Addr:              fmt.Sprintf("0.0.0.0:%d", config.Port),
+			ReadHeaderTimeout: 3 * time.Second,
+		}
 		go func() {
-			// conform to Gosec G114
-			// https://github.com/securego/gosec#available-rules
-			server := &http.Server{
-				Addr:              fmt.Sprintf("0.0.0.0:%d", config.Port),
-				ReadHeaderTimeout: 3 * time.Second,
+			if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				util.LogFatalAndExit(err, "failed to listen and serve on %s", server.Addr)
 			}
-			util.LogFatalAndExit(server.ListenAndServe(), "failed to listen and serve on %s", server.Addr)
 		}()
+	}
 
+	code := pinger.StartPinger(ctx, config)
+	if server != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			klog.Errorf("failed to shut down the metrics server: %v", err)
+		}
+		cancel()
+	}
+	if code != 0 {
+		klog.Flush()
+		os.Exit(code)
 	}
-	pinger.StartPinger(config)
 }
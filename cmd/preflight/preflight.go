@@ -0,0 +1,26 @@
+package preflight
+
+import (
+	"os"
+
+	"k8s.io/klog/v2"
+
+	"github.com/wenwenxiong/network-pinger/pkg/preflight"
+	"github.com/wenwenxiong/network-pinger/pkg/util"
+	"github.com/wenwenxiong/network-pinger/versions"
+)
+
+// CmdMain runs the bring-up checklist once, prints it and exits non zero when a check failed.
+func CmdMain() {
+	klog.Infof(versions.String())
+	config, err := preflight.ParseFlags()
+	if err != nil {
+		util.LogFatalAndExit(err, "failed to parse config")
+	}
+	results := preflight.Run(config)
+	preflight.PrintTable(os.Stdout, config, results)
+	klog.Flush()
+	if !preflight.Passed(results) {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,36 @@
+package receiver
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	"github.com/wenwenxiong/network-pinger/pkg/notifier"
+	"github.com/wenwenxiong/network-pinger/pkg/util"
+)
+
+// CmdMain runs a local webhook receiver for testing incident notifications.
+func CmdMain() {
+	defer klog.Flush()
+
+	argPort := pflag.Int("port", 8090, "webhook receiver port")
+	argLimit := pflag.Int("limit", 100, "number of payloads kept for listing")
+	klogFlags := flag.NewFlagSet("klog", flag.ExitOnError)
+	klog.InitFlags(klogFlags)
+	pflag.CommandLine.AddGoFlagSet(klogFlags)
+	pflag.Parse()
+
+	http.Handle("/", notifier.NewReceiver(*argLimit))
+	// conform to Gosec G114
+	// https://github.com/securego/gosec#available-rules
+	server := &http.Server{
+		Addr:              fmt.Sprintf("0.0.0.0:%d", *argPort),
+		ReadHeaderTimeout: 3 * time.Second,
+	}
+	klog.Infof("webhook receiver listening on %s", server.Addr)
+	util.LogFatalAndExit(server.ListenAndServe(), "failed to listen and serve on %s", server.Addr)
+}
@@ -33,6 +33,10 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&SubnetList{},
 		&IP{},
 		&IPList{},
+		&PingTarget{},
+		&PingTargetList{},
+		&PingResult{},
+		&PingResultList{},
 	)
 
 	// register the type in the scheme
@@ -60,3 +60,143 @@ type SubnetList struct {
 
 	Items []Subnet `json:"items"`
 }
+
+const (
+	ProbeTypeICMP = "icmp"
+	ProbeTypeTCP  = "tcp"
+)
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type PingTarget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PingTargetSpec `json:"spec"`
+}
+
+type PingTargetSpec struct {
+	// PodSelector selects the pods in the namespace of the PingTarget to probe.
+	PodSelector *metav1.LabelSelector `json:"podSelector,omitempty"`
+	// NodeSelector selects the nodes whose internal IPs are probed.
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+	// Addresses are static IPs or hostnames to probe.
+	Addresses []string `json:"addresses,omitempty"`
+
+	// ProbeType is icmp or tcp, defaults to icmp.
+	ProbeType string `json:"probeType,omitempty"`
+	// Port is the destination port of tcp probes.
+	Port int32 `json:"port,omitempty"`
+
+	IntervalSeconds int32 `json:"intervalSeconds,omitempty"`
+	Count           int32 `json:"count,omitempty"`
+	TimeoutSeconds  int32 `json:"timeoutSeconds,omitempty"`
+	// DSCPClasses are probed with marked icmp requests next to the unmarked probe,
+	// they replace the classes of the --dscp-classes flag for this target.
+	DSCPClasses []int32 `json:"dscpClasses,omitempty"`
+
+	Thresholds PingThresholds `json:"thresholds,omitempty"`
+}
+
+// PingThresholds decide when a probe result counts as failed. A zero MaxLossPercent fails
+// on any loss, a zero MaxRTTMilliseconds means no limit.
+type PingThresholds struct {
+	MaxLossPercent     int32 `json:"maxLossPercent,omitempty"`
+	MaxRTTMilliseconds int32 `json:"maxRttMilliseconds,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type PingTargetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []PingTarget `json:"items"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PingResult summarises the connectivity observed from one source node.
+type PingResult struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PingResultSpec   `json:"spec"`
+	Status PingResultStatus `json:"status,omitempty"`
+}
+
+type PingResultSpec struct {
+	NodeName string `json:"nodeName"`
+	NodeIP   string `json:"nodeIP,omitempty"`
+	PodName  string `json:"podName,omitempty"`
+	// Topology holds the zone, region and extra topology labels of the node.
+	Topology map[string]string `json:"topology,omitempty"`
+}
+
+type PingResultStatus struct {
+	Reachable   int32 `json:"reachable"`
+	Unreachable int32 `json:"unreachable"`
+	// Targets lists the unreachable targets first and is capped to keep the object small,
+	// OmittedTargets counts the targets left out.
+	Targets        []TargetResult     `json:"targets,omitempty"`
+	OmittedTargets int32              `json:"omittedTargets,omitempty"`
+	Conditions     []metav1.Condition `json:"conditions,omitempty"`
+	LastUpdateTime metav1.Time        `json:"lastUpdateTime,omitempty"`
+}
+
+// TargetResult is the latest probe result of one target.
+type TargetResult struct {
+	// Check is one of apiserver, dns, pod, node, ip or target.
+	Check     string `json:"check"`
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	NodeName  string `json:"nodeName,omitempty"`
+	Address   string `json:"address,omitempty"`
+	// Topology holds the topology labels of the node of the target, if any.
+	Topology map[string]string `json:"topology,omitempty"`
+
+	Reachable bool            `json:"reachable"`
+	Sent      int32           `json:"sent,omitempty"`
+	Lost      int32           `json:"lost,omitempty"`
+	AvgRTT    metav1.Duration `json:"avgRtt,omitempty"`
+	Error     string          `json:"error,omitempty"`
+
+	// PathMTU is the largest packet reaching the target, LocalMTU the mtu of the interface towards it.
+	PathMTU  int32 `json:"pathMTU,omitempty"`
+	LocalMTU int32 `json:"localMTU,omitempty"`
+
+	LastProbeTime      metav1.Time `json:"lastProbeTime,omitempty"`
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// Trace is the path to the target traced after the probe failed.
+	Trace *TraceResult `json:"trace,omitempty"`
+}
+
+// TraceResult is the outcome of an mtr style traceroute, each hop aggregates all the rounds.
+type TraceResult struct {
+	Protocol string      `json:"protocol"`
+	Reached  bool        `json:"reached"`
+	Hops     []TraceHop  `json:"hops,omitempty"`
+	Time     metav1.Time `json:"time,omitempty"`
+}
+
+type TraceHop struct {
+	TTL     int32           `json:"ttl"`
+	Address string          `json:"address,omitempty"`
+	Sent    int32           `json:"sent"`
+	Lost    int32           `json:"lost"`
+	AvgRTT  metav1.Duration `json:"avgRtt,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type PingResultList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []PingResult `json:"items"`
+}
@@ -33,6 +33,14 @@ func (c *FakeMecV1) IPs() v1.IPInterface {
 	return &FakeIPs{c}
 }
 
+func (c *FakeMecV1) PingResults() v1.PingResultInterface {
+	return &FakePingResults{c}
+}
+
+func (c *FakeMecV1) PingTargets(namespace string) v1.PingTargetInterface {
+	return &FakePingTargets{c, namespace}
+}
+
 func (c *FakeMecV1) Subnets() v1.SubnetInterface {
 	return &FakeSubnets{c}
 }
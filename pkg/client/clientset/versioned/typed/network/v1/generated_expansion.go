@@ -20,4 +20,8 @@ package v1
 
 type IPExpansion interface{}
 
+type PingResultExpansion interface{}
+
+type PingTargetExpansion interface{}
+
 type SubnetExpansion interface{}
@@ -30,6 +30,8 @@ import (
 type MecV1Interface interface {
 	RESTClient() rest.Interface
 	IPsGetter
+	PingResultsGetter
+	PingTargetsGetter
 	SubnetsGetter
 }
 
@@ -42,6 +44,14 @@ func (c *MecV1Client) IPs() IPInterface {
 	return newIPs(c)
 }
 
+func (c *MecV1Client) PingResults() PingResultInterface {
+	return newPingResults(c)
+}
+
+func (c *MecV1Client) PingTargets(namespace string) PingTargetInterface {
+	return newPingTargets(c, namespace)
+}
+
 func (c *MecV1Client) Subnets() SubnetInterface {
 	return newSubnets(c)
 }
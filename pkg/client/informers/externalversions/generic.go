@@ -56,6 +56,10 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 	// Group=mec.io, Version=v1
 	case v1.SchemeGroupVersion.WithResource("ips"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Mec().V1().IPs().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("pingresults"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Mec().V1().PingResults().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("pingtargets"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Mec().V1().PingTargets().Informer()}, nil
 	case v1.SchemeGroupVersion.WithResource("subnets"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Mec().V1().Subnets().Informer()}, nil
 
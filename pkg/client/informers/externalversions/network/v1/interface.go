@@ -26,6 +26,10 @@ import (
 type Interface interface {
 	// IPs returns a IPInformer.
 	IPs() IPInformer
+	// PingResults returns a PingResultInformer.
+	PingResults() PingResultInformer
+	// PingTargets returns a PingTargetInformer.
+	PingTargets() PingTargetInformer
 	// Subnets returns a SubnetInformer.
 	Subnets() SubnetInformer
 }
@@ -46,6 +50,16 @@ func (v *version) IPs() IPInformer {
 	return &iPInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
 }
 
+// PingResults returns a PingResultInformer.
+func (v *version) PingResults() PingResultInformer {
+	return &pingResultInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// PingTargets returns a PingTargetInformer.
+func (v *version) PingTargets() PingTargetInformer {
+	return &pingTargetInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // Subnets returns a SubnetInformer.
 func (v *version) Subnets() SubnetInformer {
 	return &subnetInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
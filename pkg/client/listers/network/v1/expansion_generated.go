@@ -22,6 +22,18 @@ package v1
 // IPLister.
 type IPListerExpansion interface{}
 
+// PingResultListerExpansion allows custom methods to be added to
+// PingResultLister.
+type PingResultListerExpansion interface{}
+
+// PingTargetListerExpansion allows custom methods to be added to
+// PingTargetLister.
+type PingTargetListerExpansion interface{}
+
+// PingTargetNamespaceListerExpansion allows custom methods to be added to
+// PingTargetNamespaceLister.
+type PingTargetNamespaceListerExpansion interface{}
+
 // SubnetListerExpansion allows custom methods to be added to
 // SubnetLister.
 type SubnetListerExpansion interface{}
@@ -0,0 +1,216 @@
+package echo
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+
+	"github.com/wenwenxiong/network-pinger/pkg/util"
+)
+
+const (
+	protocolICMPv4 = 1
+	protocolICMPv6 = 58
+
+	// every payload starts with the send time
+	timestampLength = 8
+)
+
+// Options of an echo run, which sends Count requests Interval apart and stops after Timeout.
+// TOS is the tos byte of ipv4 requests or the traffic class of ipv6 requests.
+type Options struct {
+	Count    int
+	Interval time.Duration
+	Timeout  time.Duration
+	Size     int
+	TTL      int
+	TOS      int
+}
+
+// Statistics of an echo run. Marked counts the replies whose tos or traffic class could be read,
+// Remarked those of them that came back with another dscp than the requests and ReplyTOS is the
+// marking of the last of them.
+type Statistics struct {
+	Sent     int
+	Recv     int
+	AvgRTT   time.Duration
+	Marked   int
+	Remarked int
+	ReplyTOS int
+}
+
+// Run pings the address with marked echo requests, which the ping library used for unmarked probes cannot send.
+// Canceling the context stops the run with the context error.
+func Run(ctx context.Context, address string, opts Options) (*Statistics, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip %q", address)
+	}
+	if opts.Size < timestampLength {
+		return nil, fmt.Errorf("size %d is smaller than the %d bytes of the timestamp", opts.Size, timestampLength)
+	}
+
+	ipv6Target := ip.To4() == nil
+	conn, err := listen(ipv6Target, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// wake up the pending read, the loop then sees the context is done
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	proto := protocolICMPv4
+	var msgType icmp.Type = ipv4.ICMPTypeEcho
+	if ipv6Target {
+		proto = protocolICMPv6
+		msgType = ipv6.ICMPTypeEchoRequest
+	}
+	id := util.NextICMPID()
+	dst := &net.IPAddr{IP: ip}
+	stats := &Statistics{}
+	var total time.Duration
+	received := make(map[int]bool, opts.Count)
+	buf := make([]byte, 65536)
+	oob := make([]byte, 128)
+
+	end := time.Now().Add(opts.Timeout)
+	nextSend := time.Now()
+	for time.Now().Before(end) && (stats.Sent < opts.Count || stats.Recv < stats.Sent) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if stats.Sent < opts.Count && !time.Now().Before(nextSend) {
+			payload := make([]byte, opts.Size)
+			binary.BigEndian.PutUint64(payload, uint64(time.Now().UnixNano()))
+			msg := icmp.Message{Type: msgType, Body: &icmp.Echo{ID: id, Seq: stats.Sent, Data: payload}}
+			data, err := msg.Marshal(nil)
+			if err != nil {
+				return nil, err
+			}
+			if _, err = conn.WriteTo(data, dst); err != nil {
+				return nil, err
+			}
+			stats.Sent++
+			nextSend = nextSend.Add(opts.Interval)
+		}
+
+		deadline := end
+		if stats.Sent < opts.Count && nextSend.Before(deadline) {
+			deadline = nextSend
+		}
+		if err = conn.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+		n, oobn, _, peer, err := conn.ReadMsgIP(buf, oob)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return nil, err
+		}
+
+		// ipv4 raw sockets return the ip header with the message, ipv6 ones pass the traffic class aside
+		packet, tos := buf[:n], -1
+		if ipv6Target {
+			tos = trafficClass(oob[:oobn])
+		} else {
+			if n < 20 || n < int(buf[0]&0x0f)*4 {
+				continue
+			}
+			packet, tos = buf[int(buf[0]&0x0f)*4:n], int(buf[1])
+		}
+		msg, err := icmp.ParseMessage(proto, packet)
+		if err != nil || (msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply) {
+			continue
+		}
+		reply, ok := msg.Body.(*icmp.Echo)
+		if !ok || reply.ID != id || reply.Seq >= stats.Sent || received[reply.Seq] ||
+			len(reply.Data) < timestampLength || !peer.IP.Equal(ip) {
+			continue
+		}
+		received[reply.Seq] = true
+		stats.Recv++
+		total += time.Since(time.Unix(0, int64(binary.BigEndian.Uint64(reply.Data))))
+		if tos >= 0 {
+			stats.Marked++
+			stats.ReplyTOS = tos
+			// the ecn bits may legitimately change on the way, only the dscp has to be kept
+			if tos>>2 != opts.TOS>>2 {
+				stats.Remarked++
+			}
+		}
+	}
+	if stats.Recv > 0 {
+		stats.AvgRTT = total / time.Duration(stats.Recv)
+	}
+	return stats, nil
+}
+
+// listen opens a raw icmp socket marking and limiting what it sends with the options.
+func listen(ipv6Target bool, opts Options) (*net.IPConn, error) {
+	network, address := "ip4:icmp", "0.0.0.0"
+	if ipv6Target {
+		network, address = "ip6:ipv6-icmp", "::"
+	}
+	c, err := net.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+	conn := c.(*net.IPConn)
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		set := func(level, name, value int) {
+			if sockErr == nil {
+				sockErr = syscall.SetsockoptInt(int(fd), level, name, value)
+			}
+		}
+		if ipv6Target {
+			set(syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, opts.TOS)
+			set(syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, opts.TTL)
+			set(syscall.IPPROTO_IPV6, syscall.IPV6_RECVTCLASS, 1)
+		} else {
+			set(syscall.IPPROTO_IP, syscall.IP_TOS, opts.TOS)
+			set(syscall.IPPROTO_IP, syscall.IP_TTL, opts.TTL)
+		}
+	})
+	if err == nil {
+		err = sockErr
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// trafficClass reads the traffic class from the control messages of an ipv6 packet, -1 if it is missing.
+func trafficClass(oob []byte) int {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return -1
+	}
+	for _, msg := range msgs {
+		if msg.Header.Level == syscall.IPPROTO_IPV6 && msg.Header.Type == syscall.IPV6_TCLASS && len(msg.Data) >= 4 {
+			return int(binary.NativeEndian.Uint32(msg.Data))
+		}
+	}
+	return -1
+}
@@ -0,0 +1,179 @@
+package influx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wenwenxiong/network-pinger/pkg/util"
+)
+
+// maxUDPPayload keeps the datagrams below the mtu of most links, a longer line is sent alone.
+const maxUDPPayload = 1400
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\ `)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\ `)
+	stringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`, "\n", " ")
+)
+
+// Point is one line of the influxdb line protocol, fields are int, int64, float64, bool or string.
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]interface{}
+	Time        time.Time
+}
+
+// AppendLine appends the point without trailing newline, tags with an empty value and fields
+// that are not finite numbers are left out as the protocol has no way to carry them.
+func (p *Point) AppendLine(b []byte) []byte {
+	b = append(b, measurementEscaper.Replace(p.Measurement)...)
+	keys := make([]string, 0, len(p.Tags))
+	for k, v := range p.Tags {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b = append(b, ',')
+		b = append(b, keyEscaper.Replace(k)...)
+		b = append(b, '=')
+		b = append(b, keyEscaper.Replace(p.Tags[k])...)
+	}
+
+	keys = keys[:0]
+	for k := range p.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sep := byte(' ')
+	for _, k := range keys {
+		var value []byte
+		switch v := p.Fields[k].(type) {
+		case int:
+			value = append(strconv.AppendInt(value, int64(v), 10), 'i')
+		case int64:
+			value = append(strconv.AppendInt(value, v, 10), 'i')
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			value = strconv.AppendFloat(value, v, 'f', -1, 64)
+		case bool:
+			value = strconv.AppendBool(value, v)
+		case string:
+			value = append(value, '"')
+			value = append(value, stringEscaper.Replace(v)...)
+			value = append(value, '"')
+		default:
+			continue
+		}
+		b = append(b, sep)
+		b = append(b, keyEscaper.Replace(k)...)
+		b = append(b, '=')
+		b = append(b, value...)
+		sep = ','
+	}
+	b = append(b, ' ')
+	return strconv.AppendInt(b, p.Time.UnixNano(), 10)
+}
+
+// RejectedError is returned when the server refuses the lines themselves,
+// sending them again would fail the same way.
+type RejectedError struct {
+	Status string
+	Body   string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("influxdb rejected the lines with %s: %s", e.Status, e.Body)
+}
+
+// Client writes lines to the write endpoint of influxdb or telegraf over http, or to their
+// udp listener for udp:// urls. The http url is the full write url, for example
+// http://influxdb:8086/api/v2/write?org=mec&bucket=pinger or http://telegraf:8186/write.
+type Client struct {
+	url    *url.URL
+	token  string
+	client *http.Client
+	conn   net.Conn
+}
+
+func NewClient(rawURL, token string, timeout time.Duration) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid influxdb url %q: %v", rawURL, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid influxdb url %q: no host", rawURL)
+	}
+	c := &Client{url: u, token: token}
+	switch u.Scheme {
+	case "http", "https":
+		c.client = &http.Client{Timeout: timeout}
+	case "udp":
+		if c.conn, err = net.Dial("udp", u.Host); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid influxdb url %q: http, https or udp is expected", rawURL)
+	}
+	return c, nil
+}
+
+// Write sends the lines in one request, or in as few datagrams as possible, and returns how many
+// lines were sent. A request is sent whole or not at all.
+func (c *Client) Write(ctx context.Context, lines [][]byte) (int, error) {
+	if c.conn != nil {
+		return util.WriteDatagrams(ctx, c.conn, lines, maxUDPPayload)
+	}
+	if err := c.post(ctx, lines); err != nil {
+		return 0, err
+	}
+	return len(lines), nil
+}
+
+func (c *Client) post(ctx context.Context, lines [][]byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url.String(), bytes.NewReader(bytes.Join(lines, []byte{'\n'})))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("User-Agent", "network-pinger")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Token "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	switch {
+	case resp.StatusCode/100 == 2:
+		return nil
+	case resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests &&
+		resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden:
+		return &RejectedError{Status: resp.Status, Body: strings.TrimSpace(string(body))}
+	default:
+		return fmt.Errorf("influxdb answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+}
+
+func (c *Client) Close() error {
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
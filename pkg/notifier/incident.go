@@ -0,0 +1,160 @@
+package notifier
+
+import (
+	"time"
+)
+
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Observation is one probe result as seen by the notifier.
+type Observation struct {
+	Key        string
+	Check      string
+	Target     string
+	Address    string
+	SourceNode string
+	Healthy    bool
+	Sent       int
+	Lost       int
+	AvgRTT     time.Duration
+	Error      string
+	Timestamp  time.Time
+}
+
+// Policy decides when failures of a target turn into an incident.
+type Policy struct {
+	// ConsecutiveFailures opens an incident after that many failed results in a row, 0 disables it.
+	ConsecutiveFailures int
+	// LossRatio opens an incident when the packet loss over Window exceeds it, 0 disables it.
+	LossRatio float64
+	Window    time.Duration
+	// RepeatInterval re-sends the firing incidents to alertmanager before its resolve_timeout
+	// resolves them, 0 disables it.
+	RepeatInterval time.Duration
+	// Expiry drops the targets without an observation for that long, their open incidents resolve.
+	Expiry time.Duration
+}
+
+// Incident is an open or resolved connectivity problem of one target.
+type Incident struct {
+	Status      string
+	Fingerprint string
+	Check       string
+	Target      string
+	Address     string
+	SourceNode  string
+	Reason      string
+	StartsAt    time.Time
+	EndsAt      time.Time
+	// ExpiresAt is when a firing incident not sent again is considered resolved.
+	ExpiresAt time.Time
+	Last      Observation
+}
+
+type sample struct {
+	at   time.Time
+	sent int
+	lost int
+}
+
+type targetTracker struct {
+	consecutive int
+	samples     []sample
+	incident    *Incident
+	lastSeen    time.Time
+}
+
+func (t *targetTracker) lossRatio(window time.Duration, now time.Time) float64 {
+	var sent, lost int
+	kept := t.samples[:0]
+	for _, s := range t.samples {
+		if now.Sub(s.at) > window {
+			continue
+		}
+		kept = append(kept, s)
+		sent += s.sent
+		lost += s.lost
+	}
+	t.samples = kept
+	if sent == 0 {
+		return 0
+	}
+	return float64(lost) / float64(sent)
+}
+
+// evaluate updates the tracker and returns the incident whose state changed, if any.
+func (t *targetTracker) evaluate(policy Policy, o Observation) *Incident {
+	t.lastSeen = o.Timestamp
+	if o.Healthy {
+		t.consecutive = 0
+	} else {
+		t.consecutive++
+	}
+	sent, lost := o.Sent, o.Lost
+	if sent == 0 && !o.Healthy {
+		// the probe could not run at all, count it as a fully lost attempt
+		sent, lost = 1, 1
+	}
+	t.samples = append(t.samples, sample{at: o.Timestamp, sent: sent, lost: lost})
+	ratio := t.lossRatio(policy.Window, o.Timestamp)
+
+	var reason string
+	switch {
+	case policy.ConsecutiveFailures > 0 && t.consecutive >= policy.ConsecutiveFailures:
+		reason = "consecutive failures reached threshold"
+	case policy.LossRatio > 0 && ratio > policy.LossRatio:
+		reason = "loss ratio over window exceeded threshold"
+	}
+
+	if t.incident == nil && reason != "" {
+		t.incident = &Incident{
+			Status:      StatusFiring,
+			Fingerprint: o.SourceNode + "/" + o.Key,
+			Check:       o.Check,
+			Target:      o.Target,
+			Address:     o.Address,
+			SourceNode:  o.SourceNode,
+			Reason:      reason,
+			StartsAt:    o.Timestamp,
+			Last:        o,
+		}
+		return t.firing(policy, o.Timestamp)
+	}
+	if t.incident != nil && reason == "" && o.Healthy {
+		resolved := *t.incident
+		resolved.Status = StatusResolved
+		resolved.EndsAt = o.Timestamp
+		resolved.Last = o
+		t.incident = nil
+		return &resolved
+	}
+	if t.incident != nil {
+		t.incident.Last = o
+	}
+	return nil
+}
+
+// firing returns a copy of the open incident valid for a few repeat intervals.
+func (t *targetTracker) firing(policy Policy, now time.Time) *Incident {
+	firing := *t.incident
+	if policy.RepeatInterval > 0 {
+		firing.ExpiresAt = now.Add(3 * policy.RepeatInterval)
+	}
+	return &firing
+}
+
+// expire resolves the open incident of a target no longer probed.
+func (t *targetTracker) expire(now time.Time) *Incident {
+	if t.incident == nil {
+		return nil
+	}
+	resolved := *t.incident
+	resolved.Status = StatusResolved
+	resolved.Reason = "target no longer probed"
+	resolved.EndsAt = now
+	t.incident = nil
+	return &resolved
+}
@@ -0,0 +1,361 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	FormatJSON         = "json"
+	FormatSlack        = "slack"
+	FormatAlertmanager = "alertmanager"
+)
+
+// Webhook is one receiver of incident notifications.
+type Webhook struct {
+	Format   string
+	URL      string
+	template *template.Template
+}
+
+// ParseWebhook parses a "<format>=<url>" flag value, the format is json, slack or alertmanager.
+func ParseWebhook(value string) (*Webhook, error) {
+	format, url, ok := strings.Cut(value, "=")
+	if !ok || url == "" {
+		return nil, fmt.Errorf("invalid webhook %q, expect <format>=<url>", value)
+	}
+	body, ok := builtinTemplates[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown webhook format %q", format)
+	}
+	tmpl, err := template.New(format).Funcs(templateFuncs).Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	return &Webhook{Format: format, URL: url, template: tmpl}, nil
+}
+
+// SetTemplateFile replaces the built-in body template of the webhook.
+func (w *Webhook) SetTemplateFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New(w.Format).Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return err
+	}
+	w.template = tmpl
+	return nil
+}
+
+func (w *Webhook) render(incident *Incident) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := w.template.Execute(&buf, incident); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Notifier turns probe observations into incidents and delivers them to the webhooks.
+type Notifier struct {
+	policy  Policy
+	queues  []*webhookQueue
+	client  *http.Client
+	backoff wait.Backoff
+
+	mu       sync.Mutex
+	trackers map[string]*targetTracker
+}
+
+// webhookQueue holds the pending notifications of one webhook, so an unreachable receiver
+// only delays its own. The latest notification of an incident replaces the one still pending,
+// the queue is bounded by the number of incidents and never drops a resolve.
+type webhookQueue struct {
+	webhook *Webhook
+	ready   chan struct{}
+
+	mu      sync.Mutex
+	order   []string
+	pending map[string]*Incident
+}
+
+func newWebhookQueue(w *Webhook) *webhookQueue {
+	return &webhookQueue{
+		webhook: w,
+		ready:   make(chan struct{}, 1),
+		pending: make(map[string]*Incident),
+	}
+}
+
+// push queues the incident, replacing the pending notification of the same incident.
+func (q *webhookQueue) push(incident *Incident) {
+	q.mu.Lock()
+	if _, ok := q.pending[incident.Fingerprint]; !ok {
+		q.order = append(q.order, incident.Fingerprint)
+	}
+	q.pending[incident.Fingerprint] = incident
+	q.mu.Unlock()
+	q.wake()
+}
+
+// retry queues an incident again after its delivery failed, unless a newer notification of
+// the incident is already pending.
+func (q *webhookQueue) retry(incident *Incident) {
+	q.mu.Lock()
+	if _, ok := q.pending[incident.Fingerprint]; !ok {
+		q.order = append(q.order, incident.Fingerprint)
+		q.pending[incident.Fingerprint] = incident
+	}
+	q.mu.Unlock()
+	q.wake()
+}
+
+func (q *webhookQueue) pop() *Incident {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.order) == 0 {
+		return nil
+	}
+	fingerprint := q.order[0]
+	q.order = q.order[1:]
+	incident := q.pending[fingerprint]
+	delete(q.pending, fingerprint)
+	return incident
+}
+
+func (q *webhookQueue) wake() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func NewNotifier(policy Policy, webhooks []*Webhook) *Notifier {
+	queues := make([]*webhookQueue, 0, len(webhooks))
+	for _, w := range webhooks {
+		queues = append(queues, newWebhookQueue(w))
+	}
+	return &Notifier{
+		policy: policy,
+		queues: queues,
+		client: &http.Client{Timeout: 10 * time.Second},
+		backoff: wait.Backoff{
+			Duration: time.Second,
+			Factor:   2,
+			Jitter:   0.1,
+			Steps:    5,
+		},
+		trackers: make(map[string]*targetTracker),
+	}
+}
+
+// Run delivers queued incidents until stopCh is closed, every webhook has its own worker.
+func (n *Notifier) Run(stopCh <-chan struct{}) {
+	for _, q := range n.queues {
+		go n.deliver(q, stopCh)
+	}
+	if n.policy.RepeatInterval > 0 {
+		go wait.Until(n.repeat, n.policy.RepeatInterval, stopCh)
+	}
+	if n.policy.Expiry > 0 {
+		go wait.Until(n.expire, n.policy.Expiry/2, stopCh)
+	}
+}
+
+// repeat re-sends the firing incidents so alertmanager keeps them active.
+func (n *Notifier) repeat() {
+	now := time.Now()
+	var incidents []*Incident
+	n.mu.Lock()
+	for _, tracker := range n.trackers {
+		if tracker.incident != nil {
+			incidents = append(incidents, tracker.firing(n.policy, now))
+		}
+	}
+	n.mu.Unlock()
+	for _, incident := range incidents {
+		n.enqueue(incident, true)
+	}
+}
+
+// expire drops the targets that have not been observed within the expiry, such as deleted
+// pods, and resolves their open incidents.
+func (n *Notifier) expire() {
+	now := time.Now()
+	var incidents []*Incident
+	n.mu.Lock()
+	for key, tracker := range n.trackers {
+		if now.Sub(tracker.lastSeen) < n.policy.Expiry {
+			continue
+		}
+		if incident := tracker.expire(now); incident != nil {
+			incidents = append(incidents, incident)
+		}
+		delete(n.trackers, key)
+	}
+	n.mu.Unlock()
+	for _, incident := range incidents {
+		klog.Infof("incident %s %s: %s", incident.Fingerprint, incident.Status, incident.Reason)
+		n.enqueue(incident, false)
+	}
+}
+
+// deliver sends the incidents queued for one webhook, an incident whose retries ran out is
+// queued again behind the others.
+func (n *Notifier) deliver(q *webhookQueue, stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-q.ready:
+		}
+		for incident := q.pop(); incident != nil; incident = q.pop() {
+			if err := n.send(q.webhook, incident); wait.Interrupted(err) {
+				q.retry(incident)
+			}
+			select {
+			case <-stopCh:
+				return
+			default:
+			}
+		}
+	}
+}
+
+// enqueue queues the incident for every webhook, repeats only go to alertmanager which expects
+// firing alerts to be re-sent, the other receivers are notified once per state change.
+func (n *Notifier) enqueue(incident *Incident, repeat bool) {
+	for _, q := range n.queues {
+		if repeat && q.webhook.Format != FormatAlertmanager {
+			continue
+		}
+		q.push(incident)
+	}
+}
+
+// Observe feeds a probe result, a notification is queued only when an incident opens or resolves.
+func (n *Notifier) Observe(o Observation) {
+	if o.Timestamp.IsZero() {
+		o.Timestamp = time.Now()
+	}
+	n.mu.Lock()
+	tracker, ok := n.trackers[o.Key]
+	if !ok {
+		tracker = &targetTracker{}
+		n.trackers[o.Key] = tracker
+	}
+	incident := tracker.evaluate(n.policy, o)
+	n.mu.Unlock()
+
+	if incident == nil {
+		return
+	}
+	klog.Infof("incident %s %s: %s", incident.Fingerprint, incident.Status, incident.Reason)
+	n.enqueue(incident, false)
+}
+
+func (n *Notifier) send(w *Webhook, incident *Incident) error {
+	body, err := w.render(incident)
+	if err != nil {
+		klog.Errorf("failed to render %s webhook body: %v", w.Format, err)
+		return err
+	}
+	url := w.URL
+	if w.Format == FormatAlertmanager && !strings.HasSuffix(url, "/api/v2/alerts") {
+		url = strings.TrimSuffix(url, "/") + "/api/v2/alerts"
+	}
+
+	err = wait.ExponentialBackoff(n.backoff, func() (bool, error) {
+		resp, err := n.client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			klog.Warningf("failed to post incident to %s: %v", url, err)
+			return false, nil
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			klog.Warningf("webhook %s answered %s, retry", url, resp.Status)
+			return false, nil
+		}
+		if resp.StatusCode >= 300 {
+			return false, fmt.Errorf("webhook %s rejected incident: %s", url, resp.Status)
+		}
+		return true, nil
+	})
+	if err != nil {
+		klog.Errorf("failed to deliver incident %s %s to %s: %v", incident.Fingerprint, incident.Status, url, err)
+		return err
+	}
+	klog.Infof("delivered incident %s %s to %s", incident.Fingerprint, incident.Status, url)
+	return nil
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"rfc3339": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	},
+	"ms": func(d time.Duration) string {
+		return fmt.Sprintf("%.2f", float64(d)/float64(time.Millisecond))
+	},
+}
+
+var builtinTemplates = map[string]string{
+	FormatJSON: `{
+  "status": {{ json .Status }},
+  "fingerprint": {{ json .Fingerprint }},
+  "check": {{ json .Check }},
+  "target": {{ json .Target }},
+  "address": {{ json .Address }},
+  "sourceNode": {{ json .SourceNode }},
+  "reason": {{ json .Reason }},
+  "startsAt": {{ json (rfc3339 .StartsAt) }},
+  "endsAt": {{ json (rfc3339 .EndsAt) }},
+  "sent": {{ .Last.Sent }},
+  "lost": {{ .Last.Lost }},
+  "avgRttMs": {{ ms .Last.AvgRTT }},
+  "error": {{ json .Last.Error }}
+}`,
+	FormatSlack: `{
+  "text": {{ if eq .Status "firing" -}}
+    {{ json (printf ":red_circle: %s %s (%s) unreachable from %s since %s: %s" .Check .Target .Address .SourceNode (rfc3339 .StartsAt) .Reason) }}
+  {{- else -}}
+    {{ json (printf ":large_green_circle: %s %s (%s) reachable from %s again at %s" .Check .Target .Address .SourceNode (rfc3339 .EndsAt)) }}
+  {{- end }}
+}`,
+	FormatAlertmanager: `[
+  {
+    "labels": {
+      "alertname": "NetworkPingerTargetUnreachable",
+      "severity": "critical",
+      "check": {{ json .Check }},
+      "target": {{ json .Target }},
+      "address": {{ json .Address }},
+      "source_node": {{ json .SourceNode }}
+    },
+    "annotations": {
+      "summary": {{ json (printf "%s %s unreachable from %s" .Check .Target .SourceNode) }},
+      "description": {{ json .Reason }}
+    },
+    "startsAt": {{ json (rfc3339 .StartsAt) }}{{ if eq .Status "resolved" }},
+    "endsAt": {{ json (rfc3339 .EndsAt) }}{{ else if not .ExpiresAt.IsZero }},
+    "endsAt": {{ json (rfc3339 .ExpiresAt) }}{{ end }}
+  }
+]`,
+}
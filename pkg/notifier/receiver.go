@@ -0,0 +1,67 @@
+package notifier
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// ReceivedPayload is a webhook body captured by the test receiver.
+type ReceivedPayload struct {
+	ReceivedAt time.Time       `json:"receivedAt"`
+	Path       string          `json:"path"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// Receiver is a webhook endpoint for testing notifications locally, it logs every
+// payload posted to it and lists the latest ones on GET.
+type Receiver struct {
+	limit int
+
+	mu       sync.Mutex
+	payloads []ReceivedPayload
+}
+
+func NewReceiver(limit int) *Receiver {
+	return &Receiver{limit: limit}
+}
+
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		r.mu.Lock()
+		data, err := json.MarshalIndent(r.payloads, "", "  ")
+		r.mu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	case http.MethodPost:
+		body, err := io.ReadAll(io.LimitReader(req.Body, 1<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !json.Valid(body) {
+			klog.Warningf("receive invalid json on %s: %s", req.URL.Path, string(body))
+			http.Error(w, "invalid json body", http.StatusBadRequest)
+			return
+		}
+		klog.Infof("receive webhook on %s: %s", req.URL.Path, string(body))
+		r.mu.Lock()
+		r.payloads = append(r.payloads, ReceivedPayload{ReceivedAt: time.Now(), Path: req.URL.Path, Body: body})
+		if len(r.payloads) > r.limit {
+			r.payloads = r.payloads[len(r.payloads)-r.limit:]
+		}
+		r.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
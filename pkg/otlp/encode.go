@@ -0,0 +1,205 @@
+package otlp
+
+import (
+	"math"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func unixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
+
+func convertAttribute(a Attribute) *commonpb.KeyValue {
+	value := &commonpb.AnyValue{}
+	switch v := a.Value.(type) {
+	case string:
+		value.Value = &commonpb.AnyValue_StringValue{StringValue: v}
+	case bool:
+		value.Value = &commonpb.AnyValue_BoolValue{BoolValue: v}
+	case int:
+		value.Value = &commonpb.AnyValue_IntValue{IntValue: int64(v)}
+	case int64:
+		value.Value = &commonpb.AnyValue_IntValue{IntValue: v}
+	case float64:
+		value.Value = &commonpb.AnyValue_DoubleValue{DoubleValue: v}
+	default:
+		return nil
+	}
+	return &commonpb.KeyValue{Key: a.Key, Value: value}
+}
+
+func convertAttributes(attrs []Attribute) []*commonpb.KeyValue {
+	kvs := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		if kv := convertAttribute(a); kv != nil {
+			kvs = append(kvs, kv)
+		}
+	}
+	return kvs
+}
+
+func convertScope(scope Scope) *commonpb.InstrumentationScope {
+	return &commonpb.InstrumentationScope{Name: scope.Name, Version: scope.Version}
+}
+
+func metricsRequest(resource []Attribute, scope Scope, families []*dto.MetricFamily, start, now time.Time) *colmetricspb.ExportMetricsServiceRequest {
+	scopeMetrics := &metricspb.ScopeMetrics{Scope: convertScope(scope)}
+	for _, family := range families {
+		if metric := convertMetric(family, start, now); metric != nil {
+			scopeMetrics.Metrics = append(scopeMetrics.Metrics, metric)
+		}
+	}
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource:     &resourcepb.Resource{Attributes: convertAttributes(resource)},
+			ScopeMetrics: []*metricspb.ScopeMetrics{scopeMetrics},
+		}},
+	}
+}
+
+// convertMetric maps counters to cumulative monotonic sums, gauges and untyped metrics to
+// gauges, histograms and summaries to their otlp counterparts.
+func convertMetric(family *dto.MetricFamily, start, now time.Time) *metricspb.Metric {
+	metric := &metricspb.Metric{Name: family.GetName(), Description: family.GetHelp()}
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		sum := &metricspb.Sum{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			IsMonotonic:            true,
+		}
+		for _, m := range family.GetMetric() {
+			sum.DataPoints = append(sum.DataPoints, numberPoint(m, m.GetCounter().GetValue(), start, now))
+		}
+		metric.Data = &metricspb.Metric_Sum{Sum: sum}
+	case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+		gauge := &metricspb.Gauge{}
+		for _, m := range family.GetMetric() {
+			value := m.GetGauge().GetValue()
+			if family.GetType() == dto.MetricType_UNTYPED {
+				value = m.GetUntyped().GetValue()
+			}
+			gauge.DataPoints = append(gauge.DataPoints, numberPoint(m, value, time.Time{}, now))
+		}
+		metric.Data = &metricspb.Metric_Gauge{Gauge: gauge}
+	case dto.MetricType_HISTOGRAM:
+		histogram := &metricspb.Histogram{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+		}
+		for _, m := range family.GetMetric() {
+			histogram.DataPoints = append(histogram.DataPoints, histogramPoint(m, start, now))
+		}
+		metric.Data = &metricspb.Metric_Histogram{Histogram: histogram}
+	case dto.MetricType_SUMMARY:
+		summary := &metricspb.Summary{}
+		for _, m := range family.GetMetric() {
+			summary.DataPoints = append(summary.DataPoints, summaryPoint(m, start, now))
+		}
+		metric.Data = &metricspb.Metric_Summary{Summary: summary}
+	default:
+		return nil
+	}
+	return metric
+}
+
+func labelAttributes(m *dto.Metric) []*commonpb.KeyValue {
+	kvs := make([]*commonpb.KeyValue, 0, len(m.GetLabel()))
+	for _, l := range m.GetLabel() {
+		kvs = append(kvs, convertAttribute(String(l.GetName(), l.GetValue())))
+	}
+	return kvs
+}
+
+func numberPoint(m *dto.Metric, value float64, start, now time.Time) *metricspb.NumberDataPoint {
+	return &metricspb.NumberDataPoint{
+		Attributes:        labelAttributes(m),
+		StartTimeUnixNano: unixNano(start),
+		TimeUnixNano:      unixNano(now),
+		Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+	}
+}
+
+// histogramPoint converts the cumulative prometheus buckets to the counts of every bucket on
+// its own, the last count is the one up to +Inf.
+func histogramPoint(m *dto.Metric, start, now time.Time) *metricspb.HistogramDataPoint {
+	h := m.GetHistogram()
+	sum := h.GetSampleSum()
+	point := &metricspb.HistogramDataPoint{
+		Attributes:        labelAttributes(m),
+		StartTimeUnixNano: unixNano(start),
+		TimeUnixNano:      unixNano(now),
+		Count:             h.GetSampleCount(),
+		Sum:               &sum,
+	}
+	var previous uint64
+	for _, bucket := range h.GetBucket() {
+		if math.IsInf(bucket.GetUpperBound(), 1) {
+			continue
+		}
+		point.BucketCounts = append(point.BucketCounts, bucket.GetCumulativeCount()-previous)
+		point.ExplicitBounds = append(point.ExplicitBounds, bucket.GetUpperBound())
+		previous = bucket.GetCumulativeCount()
+	}
+	point.BucketCounts = append(point.BucketCounts, h.GetSampleCount()-previous)
+	return point
+}
+
+func summaryPoint(m *dto.Metric, start, now time.Time) *metricspb.SummaryDataPoint {
+	s := m.GetSummary()
+	point := &metricspb.SummaryDataPoint{
+		Attributes:        labelAttributes(m),
+		StartTimeUnixNano: unixNano(start),
+		TimeUnixNano:      unixNano(now),
+		Count:             s.GetSampleCount(),
+		Sum:               s.GetSampleSum(),
+	}
+	for _, q := range s.GetQuantile() {
+		point.QuantileValues = append(point.QuantileValues, &metricspb.SummaryDataPoint_ValueAtQuantile{
+			Quantile: q.GetQuantile(),
+			Value:    q.GetValue(),
+		})
+	}
+	return point
+}
+
+func traceRequest(resource []Attribute, scope Scope, spans []Span) *coltracepb.ExportTraceServiceRequest {
+	scopeSpans := &tracepb.ScopeSpans{Scope: convertScope(scope)}
+	for i := range spans {
+		scopeSpans.Spans = append(scopeSpans.Spans, convertSpan(&spans[i]))
+	}
+	return &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource:   &resourcepb.Resource{Attributes: convertAttributes(resource)},
+			ScopeSpans: []*tracepb.ScopeSpans{scopeSpans},
+		}},
+	}
+}
+
+func convertSpan(span *Span) *tracepb.Span {
+	s := &tracepb.Span{
+		TraceId:           append([]byte(nil), span.TraceID[:]...),
+		SpanId:            append([]byte(nil), span.SpanID[:]...),
+		Name:              span.Name,
+		Kind:              tracepb.Span_SpanKind(span.Kind),
+		StartTimeUnixNano: unixNano(span.Start),
+		EndTimeUnixNano:   unixNano(span.End),
+		Attributes:        convertAttributes(span.Attributes),
+	}
+	if span.ParentID != [8]byte{} {
+		s.ParentSpanId = append([]byte(nil), span.ParentID[:]...)
+	}
+	if span.Status != StatusUnset {
+		s.Status = &tracepb.Status{Code: tracepb.Status_StatusCode(span.Status), Message: span.StatusMessage}
+	}
+	return s
+}
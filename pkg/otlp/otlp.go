@@ -0,0 +1,222 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	ProtocolHTTP = "http/protobuf"
+	ProtocolGRPC = "grpc"
+
+	metricsHTTPPath = "/v1/metrics"
+	tracesHTTPPath  = "/v1/traces"
+
+	userAgent = "network-pinger"
+)
+
+type SpanKind int32
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindClient   SpanKind = 3
+)
+
+type StatusCode int32
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// Attribute is a key value pair of a resource or a span, the value is a string, a bool,
+// an int, an int64 or a float64.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+func Float(key string, value float64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Scope names the instrumentation the metrics and spans come from.
+type Scope struct {
+	Name    string
+	Version string
+}
+
+// Span is a finished span, ParentID is zero for the root span of a trace.
+type Span struct {
+	TraceID       [16]byte
+	SpanID        [8]byte
+	ParentID      [8]byte
+	Name          string
+	Kind          SpanKind
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	Status        StatusCode
+	StatusMessage string
+}
+
+func NewTraceID() [16]byte {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func NewSpanID() [8]byte {
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+type Options struct {
+	// Endpoint is the url of the collector, grpc connects to its host without tls for http urls
+	// and with tls for https urls.
+	Endpoint string
+	Protocol string
+	Headers  map[string]string
+	Timeout  time.Duration
+}
+
+// Client exports metrics and spans to an otlp collector.
+type Client struct {
+	opts     Options
+	endpoint *url.URL
+	client   *http.Client
+
+	metrics colmetricspb.MetricsServiceClient
+	traces  coltracepb.TraceServiceClient
+}
+
+func NewClient(opts Options) (*Client, error) {
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid otlp endpoint %q: %v", opts.Endpoint, err)
+	}
+	if (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid otlp endpoint %q: an http or https url is expected", opts.Endpoint)
+	}
+	c := &Client{opts: opts, endpoint: endpoint, client: &http.Client{Timeout: opts.Timeout}}
+	switch opts.Protocol {
+	case ProtocolHTTP:
+	case ProtocolGRPC:
+		creds := insecure.NewCredentials()
+		if endpoint.Scheme == "https" {
+			creds = credentials.NewTLS(&tls.Config{})
+		}
+		// the connection is established lazily and kept for the life of the process
+		conn, err := grpc.Dial(endpoint.Host, grpc.WithTransportCredentials(creds), grpc.WithUserAgent(userAgent))
+		if err != nil {
+			return nil, fmt.Errorf("invalid otlp endpoint %q: %v", opts.Endpoint, err)
+		}
+		c.metrics = colmetricspb.NewMetricsServiceClient(conn)
+		c.traces = coltracepb.NewTraceServiceClient(conn)
+	default:
+		return nil, fmt.Errorf("unsupported otlp protocol %q", opts.Protocol)
+	}
+	return c, nil
+}
+
+// ExportMetrics converts the prometheus metric families to otlp metrics, counters and
+// histograms are cumulative since start.
+func (c *Client) ExportMetrics(ctx context.Context, resource []Attribute, scope Scope, families []*dto.MetricFamily, start, now time.Time) error {
+	req := metricsRequest(resource, scope, families, start, now)
+	if c.opts.Protocol == ProtocolGRPC {
+		ctx, cancel := c.grpcContext(ctx)
+		defer cancel()
+		_, err := c.metrics.Export(ctx, req)
+		return err
+	}
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return c.postHTTP(ctx, metricsHTTPPath, body)
+}
+
+func (c *Client) ExportSpans(ctx context.Context, resource []Attribute, scope Scope, spans []Span) error {
+	req := traceRequest(resource, scope, spans)
+	if c.opts.Protocol == ProtocolGRPC {
+		ctx, cancel := c.grpcContext(ctx)
+		defer cancel()
+		_, err := c.traces.Export(ctx, req)
+		return err
+	}
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return c.postHTTP(ctx, tracesHTTPPath, body)
+}
+
+func (c *Client) postHTTP(ctx context.Context, path string, body []byte) error {
+	target := *c.endpoint
+	target.Path = strings.TrimSuffix(target.Path, "/") + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	c.setHeaders(req)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp collector answered %s", resp.Status)
+	}
+	return nil
+}
+
+// grpcContext bounds a grpc call by the timeout of the client and attaches the headers as metadata.
+func (c *Client) grpcContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	for k, v := range c.opts.Headers {
+		ctx = metadata.AppendToOutgoingContext(ctx, strings.ToLower(k), v)
+	}
+	if c.opts.Timeout > 0 {
+		return context.WithTimeout(ctx, c.opts.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (c *Client) setHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", userAgent)
+	for k, v := range c.opts.Headers {
+		req.Header.Set(k, v)
+	}
+}
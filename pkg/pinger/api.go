@@ -0,0 +1,84 @@
+package pinger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// ResultsHandler serves the latest result of every target as json, the check parameter keeps
+// the targets of one check only.
+func (config *Configuration) ResultsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		check := req.URL.Query().Get("check")
+		states := config.results.snapshot(config.resultTTL())
+		if check != "" {
+			filtered := states[:0]
+			for _, state := range states {
+				if state.Check == check {
+					filtered = append(filtered, state)
+				}
+			}
+			states = filtered
+		}
+		writeJSON(w, states)
+	})
+}
+
+// HistoryHandler serves the history of the targets matching the target parameter, which is
+// the name, the address or the key of a target, since the since parameter, a duration back
+// from now or a RFC 3339 time. The check parameter keeps the targets of one check only and
+// the node parameter asks the pinger of another node.
+func (config *Configuration) HistoryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		query, check, since, ok := config.historyQuery(w, req)
+		if !ok {
+			return
+		}
+		if query == "" {
+			http.Error(w, "target parameter is required", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, config.history.history(query, check, since))
+	})
+}
+
+// OutagesHandler serves the periods the targets failed or lost packets, the parameters are
+// the ones of the history and every target with an outage is listed without target parameter.
+func (config *Configuration) OutagesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		query, check, since, ok := config.historyQuery(w, req)
+		if !ok {
+			return
+		}
+		writeJSON(w, config.history.outages(query, check, since))
+	})
+}
+
+func (config *Configuration) historyQuery(w http.ResponseWriter, req *http.Request) (string, string, time.Time, bool) {
+	if node := req.URL.Query().Get("node"); node != "" && node != config.NodeName {
+		config.proxyToNode(w, req, node)
+		return "", "", time.Time{}, false
+	}
+	if config.history == nil {
+		http.Error(w, "history is disabled", http.StatusNotFound)
+		return "", "", time.Time{}, false
+	}
+	values := req.URL.Query()
+	since, err := parseHistorySince(values.Get("since"), time.Now())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid since parameter: %v", err), http.StatusBadRequest)
+		return "", "", time.Time{}, false
+	}
+	return values.Get("target"), values.Get("check"), since, true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Errorf("failed to write response: %v", err)
+	}
+}
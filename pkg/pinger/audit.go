@@ -0,0 +1,380 @@
+package pinger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/wenwenxiong/network-pinger/pkg/util"
+	networkv1 "pkg/apis/network/v1"
+)
+
+const networkStatusAnnotation = "k8s.v1.cni.cncf.io/network-status"
+
+const (
+	AuditDuplicate   = "duplicate"
+	AuditOutOfSubnet = "out_of_subnet"
+	AuditExcluded    = "excluded"
+	AuditUnanswered  = "unanswered"
+	AuditMacMismatch = "mac_mismatch"
+	AuditDrift       = "drift"
+)
+
+var auditFindingTypes = []string{AuditDuplicate, AuditOutOfSubnet, AuditExcluded, AuditUnanswered, AuditMacMismatch, AuditDrift}
+
+// AuditFinding is a single mismatch between the IP CRDs and what is observed in the cluster.
+type AuditFinding struct {
+	Type    string   `json:"type"`
+	IP      string   `json:"ip"`
+	Subnet  string   `json:"subnet,omitempty"`
+	Owners  []string `json:"owners,omitempty"`
+	Message string   `json:"message"`
+}
+
+// AuditReport is the result of one audit run.
+type AuditReport struct {
+	NodeName   string         `json:"nodeName"`
+	Timestamp  time.Time      `json:"timestamp"`
+	CheckedIPs int            `json:"checkedIPs"`
+	Findings   []AuditFinding `json:"findings"`
+}
+
+// networkStatus is one entry of the multus network-status annotation.
+type networkStatus struct {
+	Name      string   `json:"name"`
+	Interface string   `json:"interface,omitempty"`
+	IPs       []string `json:"ips,omitempty"`
+	Mac       string   `json:"mac,omitempty"`
+	Default   bool     `json:"default,omitempty"`
+}
+
+func auditIPs(ctx context.Context, config *Configuration) error {
+	klog.Infof("start to audit ip allocations")
+	ipList, err := config.NetworkClient.MecV1().IPs().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		klog.Errorf("failed to list ips: %v", err)
+		return err
+	}
+	subnetList, err := config.NetworkClient.MecV1().Subnets().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		klog.Errorf("failed to list subnets: %v", err)
+		return err
+	}
+	// the owners of the allocations live in any namespace, the destination namespace is listed
+	// as well for the addresses in use without an allocation
+	namespaces := map[string]bool{config.destNamespace(): true}
+	for _, ip := range ipList.Items {
+		if ip.Spec.Namespace != "" {
+			namespaces[ip.Spec.Namespace] = true
+		}
+	}
+	var pods []v1.Pod
+	for namespace := range namespaces {
+		podList, err := config.KubeClient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			klog.Errorf("failed to list pods in namespace %s: %v", namespace, err)
+			return err
+		}
+		pods = append(pods, podList.Items...)
+	}
+
+	if config.arpAuditor == nil {
+		config.arpAuditor = newARPAuditor(config)
+	}
+	var onLink []string
+	for _, ip := range ipList.Items {
+		if address := ip.Spec.V4IPAddress; address != "" && util.IsOnLink(address) {
+			onLink = append(onLink, address)
+		}
+	}
+	if config.Mode == "server" {
+		// the answers of the previous resolutions are audited, the next one runs in the background
+		config.arpAuditor.schedule(ctx, onLink)
+	} else {
+		config.arpAuditor.resolve(ctx, onLink)
+	}
+
+	report := buildAuditReport(config, ipList.Items, subnetList.Items, pods, config.arpAuditor.answers())
+	SetIPAuditMetrics(report)
+	for _, f := range report.Findings {
+		klog.Warningf("ip audit %s: %s %s", f.Type, f.IP, f.Message)
+	}
+	klog.Infof("audit %d ips, %d findings", report.CheckedIPs, len(report.Findings))
+
+	if path := config.ipAuditReport(); path != "" {
+		if err = writeAuditReport(path, report); err != nil {
+			klog.Errorf("failed to write ip audit report to %s: %v", path, err)
+			return err
+		}
+	}
+	if len(report.Findings) != 0 {
+		return fmt.Errorf("ip audit found %d problems", len(report.Findings))
+	}
+	return nil
+}
+
+func buildAuditReport(config *Configuration, ips []networkv1.IP, subnets []networkv1.Subnet, pods []v1.Pod, arp map[string]arpAnswer) *AuditReport {
+	report := &AuditReport{
+		NodeName:  config.NodeName,
+		Timestamp: time.Now(),
+		Findings:  []AuditFinding{},
+	}
+
+	// addresses the pods actually carry according to their network-status annotation
+	podAddresses := make(map[string][]string)
+	podHasAddress := make(map[string]map[string]bool)
+	for _, pod := range pods {
+		key := pod.Namespace + "/" + pod.Name
+		podHasAddress[key] = make(map[string]bool)
+		for _, status := range podNetworkStatus(&pod) {
+			if status.Default {
+				continue
+			}
+			for _, address := range status.IPs {
+				address = strings.Split(address, "/")[0]
+				podAddresses[address] = append(podAddresses[address], key)
+				podHasAddress[key][address] = true
+			}
+		}
+	}
+
+	allocations := make(map[string][]string)
+	for _, ip := range ips {
+		address := ip.Spec.V4IPAddress
+		if address == "" {
+			// not allocated yet
+			continue
+		}
+		report.CheckedIPs++
+		allocations[address] = append(allocations[address], ip.Name)
+		owner := ip.Spec.Namespace + "/" + ip.Spec.UserName
+
+		subnet := findSubnet(subnets, ip.Spec.Subnet)
+		if subnet == nil {
+			report.add(AuditOutOfSubnet, address, ip.Spec.Subnet, []string{ip.Name}, "subnet of the allocation does not exist")
+		} else {
+			if !util.CIDRContainIP(subnet.Spec.CIDRBlock, address) {
+				report.add(AuditOutOfSubnet, address, subnet.Name, []string{ip.Name}, fmt.Sprintf("not inside %s", subnet.Spec.CIDRBlock))
+			}
+			if util.ContainsIPInRanges(subnet.Spec.ExcludeIps, address) || address == subnet.Spec.Gateway {
+				report.add(AuditExcluded, address, subnet.Name, []string{ip.Name}, "allocated from the excluded range of the subnet")
+			}
+		}
+
+		if addresses, ok := podHasAddress[owner]; !ok {
+			report.add(AuditDrift, address, ip.Spec.Subnet, []string{ip.Name}, fmt.Sprintf("owner pod %s not found", owner))
+		} else if !addresses[address] {
+			report.add(AuditDrift, address, ip.Spec.Subnet, []string{ip.Name, owner}, fmt.Sprintf("not configured on owner pod %s", owner))
+		}
+
+		if answer, ok := arp[address]; ok {
+			if answer.err != nil {
+				report.add(AuditUnanswered, address, ip.Spec.Subnet, []string{ip.Name}, answer.err.Error())
+			} else if ip.Spec.MacAddress != "" && !strings.EqualFold(answer.mac, ip.Spec.MacAddress) {
+				report.add(AuditMacMismatch, address, ip.Spec.Subnet, []string{ip.Name}, fmt.Sprintf("answered by %s, allocated to %s", answer.mac, ip.Spec.MacAddress))
+			}
+		}
+	}
+
+	for address, names := range allocations {
+		if len(names) > 1 {
+			report.add(AuditDuplicate, address, "", names, "allocated by more than one ip crd")
+		}
+	}
+	for address, owners := range podAddresses {
+		if len(owners) > 1 {
+			report.add(AuditDuplicate, address, "", owners, "configured on more than one pod")
+		}
+		if _, ok := allocations[address]; ok {
+			continue
+		}
+		for _, subnet := range subnets {
+			if util.CIDRContainIP(subnet.Spec.CIDRBlock, address) {
+				report.add(AuditDrift, address, subnet.Name, owners, "in use without an ip crd")
+				break
+			}
+		}
+	}
+
+	sort.Slice(report.Findings, func(i, j int) bool {
+		if report.Findings[i].Type != report.Findings[j].Type {
+			return report.Findings[i].Type < report.Findings[j].Type
+		}
+		return report.Findings[i].IP < report.Findings[j].IP
+	})
+	return report
+}
+
+type arpAnswer struct {
+	mac string
+	err error
+}
+
+// arpAuditor resolves the on-link allocations at most once per interval, in parallel up to a
+// bound, so a subnet full of silent addresses never stalls the probe cycle.
+type arpAuditor struct {
+	interval    time.Duration
+	concurrency int
+	timeout     time.Duration
+
+	mu       sync.Mutex
+	running  bool
+	last     map[string]time.Time
+	resolved map[string]arpAnswer
+}
+
+func newARPAuditor(config *Configuration) *arpAuditor {
+	return &arpAuditor{
+		interval:    config.IPAuditARPInterval,
+		concurrency: config.IPAuditARPConcurrency,
+		timeout:     time.Second,
+		last:        make(map[string]time.Time),
+		resolved:    make(map[string]arpAnswer),
+	}
+}
+
+// due forgets the addresses no longer allocated and returns the ones to resolve again.
+func (a *arpAuditor) due(addresses []string) []string {
+	allocated := make(map[string]bool, len(addresses))
+	var due []string
+	for _, address := range addresses {
+		allocated[address] = true
+		if time.Since(a.last[address]) >= a.interval {
+			due = append(due, address)
+		}
+	}
+	for address := range a.last {
+		if !allocated[address] {
+			delete(a.last, address)
+			delete(a.resolved, address)
+		}
+	}
+	return due
+}
+
+// schedule resolves the due addresses in the background unless a resolution still runs.
+func (a *arpAuditor) schedule(ctx context.Context, addresses []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	due := a.due(addresses)
+	if a.running || len(due) == 0 {
+		return
+	}
+	a.running = true
+	go func() {
+		defer func() {
+			a.mu.Lock()
+			a.running = false
+			a.mu.Unlock()
+		}()
+		a.run(ctx, due)
+	}()
+}
+
+// resolve resolves the due addresses and returns once they answered or timed out.
+func (a *arpAuditor) resolve(ctx context.Context, addresses []string) {
+	a.mu.Lock()
+	due := a.due(addresses)
+	a.mu.Unlock()
+	a.run(ctx, due)
+}
+
+func (a *arpAuditor) run(ctx context.Context, addresses []string) {
+	klog.V(3).Infof("resolve %d on-link allocations", len(addresses))
+	sem := make(chan struct{}, a.concurrency)
+	var wg sync.WaitGroup
+	for _, address := range addresses {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(address string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			mac, err := util.ArpResolve(address, a.timeout)
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			a.last[address] = time.Now()
+			a.resolved[address] = arpAnswer{mac: mac, err: err}
+		}(address)
+	}
+	wg.Wait()
+}
+
+func (a *arpAuditor) answers() map[string]arpAnswer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	answers := make(map[string]arpAnswer, len(a.resolved))
+	for address, answer := range a.resolved {
+		answers[address] = answer
+	}
+	return answers
+}
+
+func (r *AuditReport) add(findingType, ip, subnet string, owners []string, message string) {
+	r.Findings = append(r.Findings, AuditFinding{
+		Type:    findingType,
+		IP:      ip,
+		Subnet:  subnet,
+		Owners:  owners,
+		Message: message,
+	})
+}
+
+// findSubnet looks the subnet up by name first, then by cidr as some allocations record the cidr.
+func findSubnet(subnets []networkv1.Subnet, ref string) *networkv1.Subnet {
+	for i := range subnets {
+		if subnets[i].Name == ref {
+			return &subnets[i]
+		}
+	}
+	for i := range subnets {
+		if subnetMatches(&subnets[i], ref) {
+			return &subnets[i]
+		}
+	}
+	return nil
+}
+
+func subnetMatches(subnet *networkv1.Subnet, ref string) bool {
+	return subnet.Name == ref || subnet.Spec.CIDRBlock == ref
+}
+
+func podNetworkStatus(pod *v1.Pod) []networkStatus {
+	annotation := pod.Annotations[networkStatusAnnotation]
+	if annotation == "" {
+		return nil
+	}
+	var status []networkStatus
+	if err := json.Unmarshal([]byte(annotation), &status); err != nil {
+		klog.Warningf("failed to parse network status of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		return nil
+	}
+	return status
+}
+
+func writeAuditReport(path string, report *AuditReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if path == "-" {
+		_, err = fmt.Fprintln(os.Stdout, string(data))
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -3,61 +3,577 @@ package pinger
 import (
 	"context"
 	"flag"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/pflag"
+	"github.com/wenwenxiong/network-pinger/pkg/notifier"
+	"github.com/wenwenxiong/network-pinger/pkg/otlp"
+	"github.com/wenwenxiong/network-pinger/pkg/statsd"
+	"github.com/wenwenxiong/network-pinger/pkg/traceroute"
 	"github.com/wenwenxiong/network-pinger/pkg/util"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	listerappsv1 "k8s.io/client-go/listers/apps/v1"
+	listerv1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
+	"math"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	networkClientset "pkg/client/clientset/versioned"
+	networkInformer "pkg/client/informers/externalversions"
+	networkLister "pkg/client/listers/network/v1"
 )
 
 type Configuration struct {
-	KubeConfigFile     string
-	KubeClient         kubernetes.Interface
-	NetworkClient      networkClientset.Interface
-	Port               int
-	DaemonSetNamespace string
-	DestNamespace      string
-	MatchLabels	       string
-	Interval           int
-	Mode               string
-	ExitCode           int
-	InternalDNS        string
-	ExternalDNS        string
-	NodeName           string
-	HostIP             string
-	PodName            string
-	PodIP              string
-	PodProtocols       []string
-	ExternalAddress    string
-	ExternalSubnet     string
-	NetworkMode        string
-	EnableMetrics      bool
+	KubeConfigFile              string
+	KubeClient                  kubernetes.Interface
+	NetworkClient               networkClientset.Interface
+	IPLister                    networkLister.IPLister
+	SubnetLister                networkLister.SubnetLister
+	ipSynced                    cache.InformerSynced
+	subnetSynced                cache.InformerSynced
+	Port                        int
+	DaemonSetNamespace          string
+	DestNamespace               string
+	MatchLabels                 string
+	Interval                    int
+	Mode                        string
+	ExitCode                    int
+	InternalDNS                 string
+	ExternalDNS                 string
+	NodeName                    string
+	HostIP                      string
+	PodName                     string
+	PodIP                       string
+	PodProtocols                []string
+	ExternalAddress             string
+	ExternalSubnet              string
+	NetworkMode                 string
+	EnableMetrics               bool
+	EnableIPAudit               bool
+	IPAuditReport               string
+	IPAuditARPInterval          time.Duration
+	IPAuditARPConcurrency       int
+	EnableSubnetMetrics         bool
+	EnablePingTargets           bool
+	EnablePingResult            bool
+	PingResultMaxTargets        int
+	PingResultMaxTraces         int
+	PingResultHeartbeat         time.Duration
+	EnableEvents                bool
+	EventQPS                    float32
+	EventBurst                  int
+	Webhooks                    []string
+	WebhookTemplate             string
+	IncidentPolicy              notifier.Policy
+	ReportFormat                string
+	ReportOutput                string
+	CheckPolicies               map[string]string
+	ExitCodeMode                string
+	MaxLossPercent              map[string]int
+	MaxRTTMilliseconds          map[string]int
+	PushGateway                 string
+	PushJob                     string
+	RemoteWriteURL              string
+	RemoteWriteTimeout          time.Duration
+	EnableMesh                  bool
+	DaemonSetName               string
+	MeshStrategy                string
+	MeshPeers                   int
+	MeshRemoteZones             int
+	MeshCoverageWindow          time.Duration
+	TopologyKey                 string
+	TopologyLabels              map[string]string
+	EnableTraceroute            bool
+	TracerouteProtocol          string
+	TraceroutePort              int
+	TracerouteMaxHops           int
+	TracerouteRounds            int
+	TracerouteTimeout           time.Duration
+	TracerouteCooldown          time.Duration
+	TracerouteConcurrency       int
+	EnablePMTU                  bool
+	PMTUInterval                time.Duration
+	PMTUTimeout                 time.Duration
+	PMTURetries                 int
+	ProbeProfiles               map[string]ProbeProfile
+	DSCPClasses                 []int
+	DSCPConcurrency             int
+	ShutdownTimeout             time.Duration
+	ResultFile                  string
+	OTLPEndpoint                string
+	OTLPProtocol                string
+	OTLPHeaders                 map[string]string
+	OTLPInterval                time.Duration
+	OTLPTimeout                 time.Duration
+	EnableOTLPTraces            bool
+	InfluxURL                   string
+	InfluxToken                 string
+	StatsDAddress               string
+	StatsDFlavor                string
+	StatsDPrefix                string
+	ExportBatchSize             int
+	ExportBufferSize            int
+	ExportFlushInterval         time.Duration
+	ExportTimeout               time.Duration
+	EnableHistory               bool
+	HistoryRetention            time.Duration
+	HistoryResolution           time.Duration
+	HistoryDownsampledRetention time.Duration
+	HistoryMaxSamples           int
+	HistoryMaxTotalSamples      int
+	HistoryFile                 string
+	HistorySaveInterval         time.Duration
+
+	networkInformerFactory networkInformer.SharedInformerFactory
+	kubeInformerFactory    informers.SharedInformerFactory
+	podLister              listerv1.PodLister
+	daemonSetLister        listerappsv1.DaemonSetLister
+	nodeLister             listerv1.NodeLister
+	pingTargetLister       networkLister.PingTargetLister
+	results                *resultStore
+	eventRecorder          record.EventRecorder
+	notifier               *notifier.Notifier
+	meshNodes              map[string]bool
+	meshSelector           *meshSelector
+	topology               *topologyCache
+	traces                 *traceManager
+	pmtu                   *pmtuManager
+	arpAuditor             *arpAuditor
+	reloader               *configReloader
+	otel                   *otelExporter
+	exporters              []*lineBuffer
+	history                *historyStore
+	pingResults            pingResultCache
+	sinks                  []ResultSink
+	gatherer               prometheus.Gatherer
+	done                   <-chan struct{}
+	// mu guards the settings a reload changes, they are read through the accessors of configfile.go
+	mu sync.RWMutex
+}
+
+// configFlags are the values of the command line flags, they are registered on a flag set of
+// their own when the configuration file is reloaded.
+type configFlags struct {
+	Port                        *int
+	KubeConfigFile              *string
+	DaemonSetNameSpace          *string
+	DestNameSpace               *string
+	Interval                    *int
+	Mode                        *string
+	ExitCode                    *int
+	InternalDNS                 *string
+	ExternalDNS                 *string
+	ExternalAddress             *string
+	ExternalSubnet              *string
+	NetworkMode                 *string
+	EnableMetrics               *bool
+	EnableIPAudit               *bool
+	IPAuditReport               *string
+	IPAuditARPInterval          *time.Duration
+	IPAuditARPConcurrency       *int
+	EnableSubnetMetrics         *bool
+	EnablePingTargets           *bool
+	EnablePingResult            *bool
+	PingResultMaxTargets        *int
+	PingResultMaxTraces         *int
+	PingResultHeartbeat         *time.Duration
+	EnableEvents                *bool
+	EventQPS                    *float32
+	EventBurst                  *int
+	Webhooks                    *[]string
+	WebhookTemplate             *string
+	IncidentFailures            *int
+	IncidentLossRatio           *float64
+	IncidentWindow              *time.Duration
+	IncidentRepeatInterval      *time.Duration
+	IncidentExpiry              *time.Duration
+	ReportFormat                *string
+	ReportOutput                *string
+	CheckPolicies               *map[string]string
+	ExitCodeMode                *string
+	MaxLossPercent              *map[string]int
+	MaxRTTMilliseconds          *map[string]int
+	PushGateway                 *string
+	PushJob                     *string
+	RemoteWriteURL              *string
+	RemoteWriteTimeout          *time.Duration
+	EnableMesh                  *bool
+	DaemonSetName               *string
+	MeshStrategy                *string
+	MeshPeers                   *int
+	MeshRemoteZones             *int
+	MeshCoverageWindow          *time.Duration
+	TopologyKey                 *string
+	EnableTraceroute            *bool
+	TracerouteProtocol          *string
+	TraceroutePort              *int
+	TracerouteMaxHops           *int
+	TracerouteRounds            *int
+	TracerouteTimeout           *time.Duration
+	TracerouteCooldown          *time.Duration
+	TracerouteConcurrency       *int
+	EnablePMTU                  *bool
+	PMTUInterval                *time.Duration
+	PMTUTimeout                 *time.Duration
+	PMTURetries                 *int
+	ProbeProfilesFile           *string
+	ProbeProfiles               *[]string
+	DSCPClasses                 *[]int
+	DSCPConcurrency             *int
+	TopologyLabels              *map[string]string
+	ShutdownTimeout             *time.Duration
+	ResultFile                  *string
+	OTLPEndpoint                *string
+	OTLPProtocol                *string
+	OTLPHeaders                 *map[string]string
+	OTLPInterval                *time.Duration
+	OTLPTimeout                 *time.Duration
+	EnableOTLPTraces            *bool
+	InfluxURL                   *string
+	InfluxToken                 *string
+	StatsDAddress               *string
+	StatsDFlavor                *string
+	StatsDPrefix                *string
+	ExportBatchSize             *int
+	ExportBufferSize            *int
+	ExportFlushInterval         *time.Duration
+	ExportTimeout               *time.Duration
+	EnableHistory               *bool
+	HistoryRetention            *time.Duration
+	HistoryResolution           *time.Duration
+	HistoryDownsampledRetention *time.Duration
+	HistoryMaxSamples           *int
+	HistoryMaxTotalSamples      *int
+	HistoryFile                 *string
+	HistorySaveInterval         *time.Duration
+
+	ConfigFile           *string
+	ConfigReloadInterval *time.Duration
+	ConfigSchema         *bool
+
+	// probe profiles of the configuration file, which are no flag
+	fileProbeProfiles map[string]ProbeProfile
+}
+
+func registerFlags(fs *pflag.FlagSet) *configFlags {
+	f := &configFlags{}
+	f.Port = fs.Int("port", 8080, "metrics port")
+
+	f.KubeConfigFile = fs.String("kubeconfig", "", "Path to kubeconfig file with authorization and master location information. If not set use the inCluster token.")
+	f.DaemonSetNameSpace = fs.String("ds-namespace", "kube-system", "network-pinger deployment namespace")
+	f.DestNameSpace = fs.String("dest-namespace", "", "network-pinger ping pod in dest namespace")
+	f.Interval = fs.Int("interval", 5, "interval seconds between consecutive pings")
+	f.Mode = fs.String("mode", "server", "server or job Mode")
+	f.ExitCode = fs.Int("exit-code", 0, "exit code when failure happens")
+	f.InternalDNS = fs.String("internal-dns", "kubernetes.default", "check dns from pod")
+	f.ExternalDNS = fs.String("external-dns", "", "check external dns resolve from pod")
+	f.ExternalAddress = fs.String("external-address", "", "check ping connection to an external address with the external probe profile, for example 114.114.114.114, empty skips the check")
+	f.ExternalSubnet = fs.String("external-subnet", "172.18.11.0/24", "check ping connection to an external subnet, default: 172.18.11.0/24")
+
+	f.NetworkMode = fs.String("network-mode", "kube-ovn", "The cni plugin current cluster used, default: kube-ovn")
+	f.EnableMetrics = fs.Bool("enable-metrics", true, "Whether to support metrics query")
+	f.EnableIPAudit = fs.Bool("enable-ip-audit", false, "Whether to cross-check ip crds against pod network status, subnets and arp answers")
+	f.IPAuditReport = fs.String("ip-audit-report", "", "Path to write the json ip audit report to, - for stdout")
+	f.IPAuditARPInterval = fs.Duration("ip-audit-arp-interval", 5*time.Minute, "Minimum time between two arp resolutions of the same on-link allocation")
+	f.IPAuditARPConcurrency = fs.Int("ip-audit-arp-concurrency", 16, "Maximum number of arp resolutions running at the same time")
+	f.EnableSubnetMetrics = fs.Bool("enable-subnet-metrics", true, "Whether to export subnet capacity and utilisation from subnet and ip crds, ipv6 blocks only export their capacity as ip crds carry no ipv6 address")
+	f.EnablePingTargets = fs.Bool("enable-ping-targets", false, "Whether to probe the targets declared by PingTarget crds")
+	f.EnablePingResult = fs.Bool("enable-ping-result", false, "Whether to publish the latest results of this node as a PingResult crd")
+	f.PingResultMaxTargets = fs.Int("ping-result-max-targets", 100, "Maximum number of targets listed in the PingResult status, unreachable targets first")
+	f.PingResultMaxTraces = fs.Int("ping-result-max-traces", 10, "Maximum number of traceroutes kept in the PingResult status")
+	f.PingResultHeartbeat = fs.Duration("ping-result-heartbeat", 2*time.Minute, "Interval the PingResult status is rewritten at when no target changed state, to refresh its lastUpdateTime and rtts")
+	f.EnableEvents = fs.Bool("enable-events", true, "Whether to emit kubernetes events when a target becomes unreachable or recovers")
+	f.EventQPS = fs.Float32("event-qps", 1.0/60, "Sustained events per second allowed for one object")
+	f.EventBurst = fs.Int("event-burst", 10, "Burst of events allowed for one object")
+	f.Webhooks = fs.StringArray("webhook", nil, "Webhook to notify incidents to as <format>=<url>, format is json, slack or alertmanager, can be repeated")
+	f.WebhookTemplate = fs.String("webhook-template", "", "Path of a go template replacing the body of json webhooks")
+	f.IncidentFailures = fs.Int("incident-consecutive-failures", 3, "Open an incident after that many consecutive failed probes of a target, 0 to disable")
+	f.IncidentLossRatio = fs.Float64("incident-loss-ratio", 0, "Open an incident when the loss ratio of a target over the incident window exceeds it, 0 to disable")
+	f.IncidentWindow = fs.Duration("incident-window", 5*time.Minute, "Window the incident loss ratio is computed over")
+	f.IncidentRepeatInterval = fs.Duration("incident-repeat-interval", time.Minute, "Interval firing incidents are re-sent to alertmanager webhooks at, keep it below the resolve_timeout of alertmanager, 0 to disable")
+	f.IncidentExpiry = fs.Duration("incident-expiry", 10*time.Minute, "Time after which a target no longer probed is forgotten and its open incident resolved")
+	f.ReportFormat = fs.String("report-format", "", "Format of the report written at the end of job mode: json, junit or markdown, empty disables the report")
+	f.ReportOutput = fs.String("report-output", "-", "Path of the job mode report, - for stdout")
+	f.CheckPolicies = fs.StringToString("check-policy", nil, "Policy of checks in job mode as check=critical|warning|ignore, checks are apiserver, pod, node, dns, externaldns, ip, external, target, ipaudit and subnet, unlisted checks are critical")
+	f.ExitCodeMode = fs.String("exit-code-mode", ExitCodeModeSingle, "single exits with --exit-code when a critical check fails, bitmask exits with one bit per failed critical check: apiserver 1, pod 2, node 4, dns and externaldns 8, ip and external 16, target 32, ipaudit 64, subnet 128")
+	f.MaxLossPercent = fs.StringToInt("max-loss-percent", nil, "Packet loss percentage above which a result fails in job mode as check=percent, for example pod=10, unlisted checks fail on any loss and ping targets use the thresholds of their spec")
+	f.MaxRTTMilliseconds = fs.StringToInt("max-rtt-ms", nil, "Average rtt in milliseconds above which a result fails in job mode as check=ms, for example apiserver=500, unlisted checks have no limit and ping targets use the thresholds of their spec")
+	f.PushGateway = fs.String("push-gateway", "", "Pushgateway url the metrics are pushed to at the end of job mode")
+	f.PushJob = fs.String("push-job", "network-pinger", "Job label of the metrics pushed in job mode, the node label is always added")
+	f.RemoteWriteURL = fs.String("remote-write-url", "", "Prometheus remote write url the metrics are sent to at the end of job mode")
+	f.RemoteWriteTimeout = fs.Duration("remote-write-timeout", 30*time.Second, "Timeout of the remote write request")
+	f.EnableMesh = fs.Bool("enable-mesh", false, "Probe the other pods of the pinger daemonset instead of every node, pod-to-pod from the pod network or host-to-host from the host network")
+	f.DaemonSetName = fs.String("ds-name", "network-pinger", "network-pinger daemonset name, used to find the mesh peers")
+	f.MeshStrategy = fs.String("mesh-strategy", MeshStrategyFull, "How mesh peers are picked every cycle: full, random (k peers rotating), hash (k peers by consistent hashing) or topology (own zone and k remote zones)")
+	f.MeshPeers = fs.Int("mesh-peers", 10, "Number of peers probed per cycle by the random and hash mesh strategies")
+	f.MeshRemoteZones = fs.Int("mesh-remote-zones", 2, "Number of remote zones probed per cycle by the topology mesh strategy")
+	f.MeshCoverageWindow = fs.Duration("mesh-coverage-window", 10*time.Minute, "Window the mesh coverage ratio is computed over")
+	f.TopologyKey = fs.String("topology-key", "topology.kubernetes.io/zone", "Node label grouping nodes into zones for the topology mesh strategy")
+	f.EnableTraceroute = fs.Bool("enable-traceroute", false, "Trace the path to a target when its probe fails")
+	f.TracerouteProtocol = fs.String("traceroute-protocol", "icmp", "Protocol of the traceroute probes: icmp, udp or tcp")
+	f.TraceroutePort = fs.Int("traceroute-port", 33434, "Destination port of udp traceroute probes, the first of the range, or of tcp traceroute probes")
+	f.TracerouteMaxHops = fs.Int("traceroute-max-hops", 16, "Maximum ttl of the traceroute probes")
+	f.TracerouteRounds = fs.Int("traceroute-rounds", 3, "Number of rounds of a traceroute, hop loss and rtt aggregate all the rounds")
+	f.TracerouteTimeout = fs.Duration("traceroute-timeout", time.Second, "Time to wait for the answer to a traceroute probe")
+	f.TracerouteCooldown = fs.Duration("traceroute-cooldown", 5*time.Minute, "Minimum time between two traceroutes of the same target")
+	f.TracerouteConcurrency = fs.Int("traceroute-concurrency", 4, "Maximum number of traceroutes running at the same time, failing targets beyond it are traced after a later failure")
+	f.EnablePMTU = fs.Bool("enable-pmtu", false, "Discover the path mtu of reachable targets and export the mismatches with the local interface mtu")
+	f.PMTUInterval = fs.Duration("pmtu-interval", 30*time.Minute, "Minimum time between two path mtu discoveries of the same target")
+	f.PMTUTimeout = fs.Duration("pmtu-timeout", time.Second, "Time to wait for the answer to a path mtu probe")
+	f.PMTURetries = fs.Int("pmtu-retries", 2, "Number of probes of a size before it is considered too large for the path")
+	f.ProbeProfilesFile = fs.String("probe-profiles-file", "", "YAML or JSON file with the probe profile of each target class: pod, node, ip and external")
+	f.ProbeProfiles = fs.StringArray("probe-profile", nil, "Probe profile override as class:key=value,..., keys are count, interval, timeout, size, ttl and tos, for example node:count=5,timeout=5s")
+	f.DSCPClasses = fs.IntSlice("dscp-classes", nil, "DSCP classes every icmp target is additionally probed with, for example 46,34 for voice and video, replies are checked to keep the marking")
+	f.DSCPConcurrency = fs.Int("dscp-concurrency", 4, "Maximum number of dscp classes of a target probed at the same time")
+	f.ShutdownTimeout = fs.Duration("shutdown-timeout", 20*time.Second, "Time allowed to flush the latest results and metrics after SIGTERM, keep it below the termination grace period of the pod")
+	f.ResultFile = fs.String("result-file", "", "Path of a file every reachability result is appended to as a json line, - for stdout")
+	f.OTLPEndpoint = fs.String("otlp-endpoint", "", "OTLP collector url the metrics are exported to next to prometheus, for example http://otel-collector:4318, empty disables the export")
+	f.OTLPProtocol = fs.String("otlp-protocol", otlp.ProtocolHTTP, "OTLP protocol: http/protobuf or grpc, grpc connects without tls for http urls and with tls for https urls")
+	f.OTLPHeaders = fs.StringToString("otlp-headers", nil, "Headers sent with every OTLP request as name=value, for example authorization=Bearer <token>")
+	f.OTLPInterval = fs.Duration("otlp-interval", 30*time.Second, "Interval between two OTLP metric exports in server mode")
+	f.OTLPTimeout = fs.Duration("otlp-timeout", 10*time.Second, "Timeout of an OTLP export")
+	f.EnableOTLPTraces = fs.Bool("enable-otlp-traces", false, "Export a span per probe cycle with a child span per probed target to the OTLP collector")
+	f.InfluxURL = fs.String("influx-url", "", "InfluxDB or Telegraf write url every reachability result is pushed to as line protocol, for example http://influxdb:8086/api/v2/write?org=mec&bucket=pinger or udp://telegraf:8089")
+	f.InfluxToken = fs.String("influx-token", "", "Token of the InfluxDB write url")
+	f.StatsDAddress = fs.String("statsd-address", "", "StatsD server host:port every reachability result is sent to over udp")
+	f.StatsDFlavor = fs.String("statsd-flavor", statsd.FlavorDogStatsD, "How tags are sent to the statsd server: dogstatsd appends them after |#, statsd puts them in the metric name like telegraf reads them")
+	f.StatsDPrefix = fs.String("statsd-prefix", "pinger", "Prefix of the statsd metric names")
+	f.ExportBatchSize = fs.Int("export-batch-size", 1000, "Maximum number of lines the influxdb and statsd exporters send at once")
+	f.ExportBufferSize = fs.Int("export-buffer-size", 100000, "Number of lines the influxdb and statsd exporters keep while their collector is unreachable, the oldest are dropped beyond, udp collectors only hold lines back when the kernel refuses to send them")
+	f.ExportFlushInterval = fs.Duration("export-flush-interval", 10*time.Second, "Maximum time a result waits in the influxdb and statsd exporters before it is sent")
+	f.ExportTimeout = fs.Duration("export-timeout", 10*time.Second, "Timeout of a write of the influxdb and statsd exporters")
+	f.EnableHistory = fs.Bool("enable-history", true, "Keep the recent results of every target for the history and outages apis")
+	f.HistoryRetention = fs.Duration("history-retention", 24*time.Hour, "How long the results of a target are kept at full resolution")
+	f.HistoryResolution = fs.Duration("history-resolution", 5*time.Minute, "Period the results older than the history retention are aggregated over")
+	f.HistoryDownsampledRetention = fs.Duration("history-downsampled-retention", 7*24*time.Hour, "How long the aggregated results of a target are kept, no longer than the history retention disables the aggregation")
+	f.HistoryMaxSamples = fs.Int("history-max-samples", 17280, "Maximum number of results of a target kept at full resolution, the oldest are aggregated beyond")
+	f.HistoryMaxTotalSamples = fs.Int("history-max-total-samples", 500000, "Maximum number of history points of all targets, about 100 bytes each, shared evenly by the targets, at most half of the share of a target holds its aggregated results")
+	f.HistoryFile = fs.String("history-file", "", "Path of a file the history is saved to and restored from across restarts, empty keeps it in memory only")
+	f.HistorySaveInterval = fs.Duration("history-save-interval", 5*time.Minute, "Interval to save the history to the history file")
+	f.ConfigFile = fs.String("config", "", "YAML or JSON configuration file keyed by flag names, with probe-profiles holding the probe profiles, flags given on the command line override it and changes are applied without a restart")
+	f.ConfigReloadInterval = fs.Duration("config-reload-interval", 10*time.Second, "How often the configuration file is checked for changes, 0 disables the reload")
+	f.ConfigSchema = fs.Bool("config-schema", false, "Print the json schema of the configuration file and exit")
+	f.TopologyLabels = fs.StringToString("topology-labels", nil, "Extra topology levels as level=node-label, for example site=mec.io/site, zone and region are always read")
+	return f
+}
+
+// configuration builds and validates the configuration from the flag values, without any client.
+func (f *configFlags) configuration() (*Configuration, error) {
+	config := &Configuration{
+		KubeConfigFile:        *f.KubeConfigFile,
+		KubeClient:            nil,
+		NetworkClient:         nil,
+		Port:                  *f.Port,
+		DaemonSetNamespace:    *f.DaemonSetNameSpace,
+		DestNamespace:         *f.DestNameSpace,
+		Interval:              *f.Interval,
+		Mode:                  *f.Mode,
+		ExitCode:              *f.ExitCode,
+		InternalDNS:           *f.InternalDNS,
+		ExternalDNS:           *f.ExternalDNS,
+		PodIP:                 os.Getenv("POD_IP"),
+		HostIP:                os.Getenv("HOST_IP"),
+		NodeName:              os.Getenv("NODE_NAME"),
+		PodName:               os.Getenv("POD_NAME"),
+		ExternalAddress:       *f.ExternalAddress,
+		ExternalSubnet:        *f.ExternalSubnet,
+		NetworkMode:           *f.NetworkMode,
+		EnableMetrics:         *f.EnableMetrics,
+		EnableIPAudit:         *f.EnableIPAudit,
+		IPAuditReport:         *f.IPAuditReport,
+		IPAuditARPInterval:    *f.IPAuditARPInterval,
+		IPAuditARPConcurrency: *f.IPAuditARPConcurrency,
+		EnableSubnetMetrics:   *f.EnableSubnetMetrics,
+		EnablePingTargets:     *f.EnablePingTargets,
+		EnablePingResult:      *f.EnablePingResult,
+		PingResultMaxTargets:  *f.PingResultMaxTargets,
+		PingResultMaxTraces:   *f.PingResultMaxTraces,
+		PingResultHeartbeat:   *f.PingResultHeartbeat,
+		EnableEvents:          *f.EnableEvents,
+		EventQPS:              *f.EventQPS,
+		EventBurst:            *f.EventBurst,
+		Webhooks:              *f.Webhooks,
+		WebhookTemplate:       *f.WebhookTemplate,
+		IncidentPolicy: notifier.Policy{
+			ConsecutiveFailures: *f.IncidentFailures,
+			LossRatio:           *f.IncidentLossRatio,
+			Window:              *f.IncidentWindow,
+			RepeatInterval:      *f.IncidentRepeatInterval,
+			Expiry:              *f.IncidentExpiry,
+		},
+		ReportFormat:                *f.ReportFormat,
+		ReportOutput:                *f.ReportOutput,
+		CheckPolicies:               *f.CheckPolicies,
+		ExitCodeMode:                *f.ExitCodeMode,
+		MaxLossPercent:              *f.MaxLossPercent,
+		MaxRTTMilliseconds:          *f.MaxRTTMilliseconds,
+		PushGateway:                 *f.PushGateway,
+		PushJob:                     *f.PushJob,
+		RemoteWriteURL:              *f.RemoteWriteURL,
+		RemoteWriteTimeout:          *f.RemoteWriteTimeout,
+		EnableMesh:                  *f.EnableMesh,
+		DaemonSetName:               *f.DaemonSetName,
+		MeshStrategy:                *f.MeshStrategy,
+		MeshPeers:                   *f.MeshPeers,
+		MeshRemoteZones:             *f.MeshRemoteZones,
+		MeshCoverageWindow:          *f.MeshCoverageWindow,
+		TopologyKey:                 *f.TopologyKey,
+		TopologyLabels:              *f.TopologyLabels,
+		EnableTraceroute:            *f.EnableTraceroute,
+		TracerouteProtocol:          *f.TracerouteProtocol,
+		TraceroutePort:              *f.TraceroutePort,
+		TracerouteMaxHops:           *f.TracerouteMaxHops,
+		TracerouteRounds:            *f.TracerouteRounds,
+		TracerouteTimeout:           *f.TracerouteTimeout,
+		TracerouteCooldown:          *f.TracerouteCooldown,
+		TracerouteConcurrency:       *f.TracerouteConcurrency,
+		EnablePMTU:                  *f.EnablePMTU,
+		PMTUInterval:                *f.PMTUInterval,
+		PMTUTimeout:                 *f.PMTUTimeout,
+		PMTURetries:                 *f.PMTURetries,
+		DSCPClasses:                 *f.DSCPClasses,
+		DSCPConcurrency:             *f.DSCPConcurrency,
+		ShutdownTimeout:             *f.ShutdownTimeout,
+		ResultFile:                  *f.ResultFile,
+		OTLPEndpoint:                *f.OTLPEndpoint,
+		OTLPProtocol:                *f.OTLPProtocol,
+		OTLPHeaders:                 *f.OTLPHeaders,
+		OTLPInterval:                *f.OTLPInterval,
+		OTLPTimeout:                 *f.OTLPTimeout,
+		EnableOTLPTraces:            *f.EnableOTLPTraces,
+		InfluxURL:                   *f.InfluxURL,
+		InfluxToken:                 *f.InfluxToken,
+		StatsDAddress:               *f.StatsDAddress,
+		StatsDFlavor:                *f.StatsDFlavor,
+		StatsDPrefix:                *f.StatsDPrefix,
+		ExportBatchSize:             *f.ExportBatchSize,
+		ExportBufferSize:            *f.ExportBufferSize,
+		ExportFlushInterval:         *f.ExportFlushInterval,
+		ExportTimeout:               *f.ExportTimeout,
+		EnableHistory:               *f.EnableHistory,
+		HistoryRetention:            *f.HistoryRetention,
+		HistoryResolution:           *f.HistoryResolution,
+		HistoryDownsampledRetention: *f.HistoryDownsampledRetention,
+		HistoryMaxSamples:           *f.HistoryMaxSamples,
+		HistoryMaxTotalSamples:      *f.HistoryMaxTotalSamples,
+		HistoryFile:                 *f.HistoryFile,
+		HistorySaveInterval:         *f.HistorySaveInterval,
+		results:                     newResultStore(),
+		topology:                    newTopologyCache(*f.TopologyLabels),
+	}
+	profiles, err := loadProbeProfiles(*f.ProbeProfilesFile, f.fileProbeProfiles, *f.ProbeProfiles)
+	if err != nil {
+		return nil, err
+	}
+	config.ProbeProfiles = profiles
+	if err = config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// validate checks the settings that need no client, for configurations built from flags as
+// well as the ones built by programs embedding the pinger.
+func (config *Configuration) validate() error {
+	switch config.ReportFormat {
+	case "", ReportFormatJSON, ReportFormatJUnit, ReportFormatMarkdown:
+	default:
+		return fmt.Errorf("unsupported report format %q", config.ReportFormat)
+	}
+	if config.ExitCodeMode != ExitCodeModeSingle && config.ExitCodeMode != ExitCodeModeBitmask {
+		return fmt.Errorf("unsupported exit code mode %q", config.ExitCodeMode)
+	}
+	if err := validateCheckPolicies(config.CheckPolicies); err != nil {
+		return err
+	}
+	if err := validateCheckThresholds("max loss percent", config.MaxLossPercent, 100); err != nil {
+		return err
+	}
+	if err := validateCheckThresholds("max rtt", config.MaxRTTMilliseconds, math.MaxInt32); err != nil {
+		return err
+	}
+	for class := range defaultProbeProfiles() {
+		profile, ok := config.ProbeProfiles[class]
+		if !ok {
+			return fmt.Errorf("missing %s probe profile", class)
+		}
+		if err := profile.validate(); err != nil {
+			return fmt.Errorf("invalid %s probe profile: %v", class, err)
+		}
+	}
+	if err := validateDSCPClasses(config.DSCPClasses); err != nil {
+		return err
+	}
+	if config.DSCPConcurrency <= 0 {
+		return fmt.Errorf("dscp concurrency must be positive")
+	}
+	if config.EnableTraceroute {
+		switch config.TracerouteProtocol {
+		case traceroute.ProtocolICMP, traceroute.ProtocolUDP, traceroute.ProtocolTCP:
+		default:
+			return fmt.Errorf("unsupported traceroute protocol %q", config.TracerouteProtocol)
+		}
+		if config.TracerouteConcurrency <= 0 {
+			return fmt.Errorf("traceroute concurrency must be positive")
+		}
+	}
+	if len(config.Webhooks) != 0 {
+		if config.IncidentPolicy.RepeatInterval < 0 {
+			return fmt.Errorf("incident repeat interval must not be negative")
+		}
+		if config.IncidentPolicy.Expiry <= time.Duration(config.Interval)*time.Second {
+			return fmt.Errorf("incident expiry must be longer than the probe interval")
+		}
+	}
+	if config.PingResultMaxTargets < 0 || config.PingResultMaxTraces < 0 {
+		return fmt.Errorf("ping result max targets and max traces must not be negative")
+	}
+	if config.EnablePingResult && config.PingResultHeartbeat <= 0 {
+		return fmt.Errorf("ping result heartbeat must be positive")
+	}
+	if config.EnableIPAudit && config.IPAuditARPConcurrency <= 0 {
+		return fmt.Errorf("ip audit arp concurrency must be positive")
+	}
+	if config.EnablePMTU && (config.PMTUTimeout <= 0 || config.PMTURetries <= 0) {
+		return fmt.Errorf("pmtu timeout and retries must be positive")
+	}
+	if config.OTLPEndpoint != "" && (config.OTLPInterval <= 0 || config.OTLPTimeout <= 0) {
+		return fmt.Errorf("otlp interval and timeout must be positive")
+	}
+	if config.InfluxURL != "" || config.StatsDAddress != "" {
+		if config.ExportBatchSize <= 0 || config.ExportBufferSize < config.ExportBatchSize {
+			return fmt.Errorf("export batch size must be positive and the export buffer size at least the batch size")
+		}
+		if config.ExportFlushInterval <= 0 || config.ExportTimeout <= 0 {
+			return fmt.Errorf("export flush interval and timeout must be positive")
+		}
+	}
+	if config.StatsDAddress != "" && config.StatsDFlavor != statsd.FlavorStatsD && config.StatsDFlavor != statsd.FlavorDogStatsD {
+		return fmt.Errorf("unsupported statsd flavor %q", config.StatsDFlavor)
+	}
+	if config.EnableHistory {
+		if config.HistoryRetention <= 0 || config.HistoryResolution <= 0 || config.HistoryMaxSamples <= 0 || config.HistoryMaxTotalSamples <= 0 {
+			return fmt.Errorf("history retention, resolution, max samples and max total samples must be positive")
+		}
+		if config.HistoryFile != "" && config.HistorySaveInterval <= 0 {
+			return fmt.Errorf("history save interval must be positive")
+		}
+	}
+	return nil
 }
 
 func ParseFlags() (*Configuration, error) {
-	var (
-		argPort = pflag.Int("port", 8080, "metrics port")
-
-		argKubeConfigFile     = pflag.String("kubeconfig", "", "Path to kubeconfig file with authorization and master location information. If not set use the inCluster token.")
-		argDaemonSetNameSpace = pflag.String("ds-namespace", "kube-system", "network-pinger deployment namespace")
-		argDestNameSpace      = pflag.String("dest-namespace", "", "network-pinger ping pod in dest namespace")
-		argInterval           = pflag.Int("interval", 5, "interval seconds between consecutive pings")
-		argMode               = pflag.String("mode", "server", "server or job Mode")
-		argExitCode           = pflag.Int("exit-code", 0, "exit code when failure happens")
-		argInternalDNS        = pflag.String("internal-dns", "kubernetes.default", "check dns from pod")
-		argExternalDNS        = pflag.String("external-dns", "", "check external dns resolve from pod")
-		argExternalAddress    = pflag.String("external-address", "", "check ping connection to an external address, default: 114.114.114.114")
-		argExternalSubnet     = pflag.String("external-subnet", "172.18.11.0/24", "check ping connection to an external subnet, default: 172.18.11.0/24")
-
-		argNetworkMode        = pflag.String("network-mode", "kube-ovn", "The cni plugin current cluster used, default: kube-ovn")
-		argEnableMetrics      = pflag.Bool("enable-metrics", true, "Whether to support metrics query")
-	)
+	args := registerFlags(pflag.CommandLine)
 	klogFlags := flag.NewFlagSet("klog", flag.ExitOnError)
 	klog.InitFlags(klogFlags)
 
@@ -76,28 +592,30 @@ func ParseFlags() (*Configuration, error) {
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 	pflag.Parse()
 
-	config := &Configuration{
-		KubeConfigFile:     *argKubeConfigFile,
-		KubeClient:         nil,
-		NetworkClient:       nil,
-		Port:               *argPort,
-		DaemonSetNamespace: *argDaemonSetNameSpace,
-		DestNamespace:      *argDestNameSpace,
-		Interval:           *argInterval,
-		Mode:               *argMode,
-		ExitCode:           *argExitCode,
-		InternalDNS:        *argInternalDNS,
-		ExternalDNS:        *argExternalDNS,
-		PodIP:              os.Getenv("POD_IP"),
-		HostIP:             os.Getenv("HOST_IP"),
-		NodeName:           os.Getenv("NODE_NAME"),
-		PodName:            os.Getenv("POD_NAME"),
-		ExternalAddress:    *argExternalAddress,
-		ExternalSubnet:     *argExternalSubnet,
-		NetworkMode:        *argNetworkMode,
-		EnableMetrics:      *argEnableMetrics,
-	}
-	if err := config.initKubeClient(); err != nil {
+	if *args.ConfigSchema {
+		schema, err := configSchema(pflag.CommandLine)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println(string(schema))
+		os.Exit(0)
+	}
+	if *args.ConfigFile != "" {
+		if err := args.loadConfigFile(pflag.CommandLine); err != nil {
+			return nil, err
+		}
+	}
+
+	config, err := args.configuration()
+	if err != nil {
+		return nil, err
+	}
+	if *args.ConfigFile != "" && *args.ConfigReloadInterval > 0 {
+		if config.reloader, err = newConfigReloader(*args.ConfigFile, *args.ConfigReloadInterval, pflag.CommandLine); err != nil {
+			return nil, err
+		}
+	}
+	if err = config.init(EngineOptions{}); err != nil {
 		return nil, err
 	}
 
@@ -133,6 +651,107 @@ func ParseFlags() (*Configuration, error) {
 	return config, nil
 }
 
+// redactedFields hold credentials, their values are masked wherever the configuration is logged.
+var redactedFields = map[string]bool{
+	"OTLPHeaders": true,
+	"InfluxToken": true,
+}
+
+// String prints the exported settings the way %+v does, with the credentials masked.
+func (config *Configuration) String() string {
+	v := reflect.ValueOf(config).Elem()
+	fields := make([]string, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		value := v.Field(i).Interface()
+		if redactedFields[field.Name] {
+			value = redact(v.Field(i))
+		}
+		fields = append(fields, fmt.Sprintf("%s:%+v", field.Name, value))
+	}
+	return "{" + strings.Join(fields, " ") + "}"
+}
+
+// redact keeps the keys of a map and whether a string is set.
+func redact(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Map:
+		masked := make(map[string]string, v.Len())
+		for _, key := range v.MapKeys() {
+			masked[fmt.Sprint(key.Interface())] = redactedValue
+		}
+		return masked
+	case reflect.String:
+		if v.Len() != 0 {
+			return redactedValue
+		}
+	}
+	return v.Interface()
+}
+
+// init creates the background managers and the clients the options do not inject.
+func (config *Configuration) init(opts EngineOptions) error {
+	if config.results == nil {
+		config.results = newResultStore()
+	}
+	if config.topology == nil {
+		config.topology = newTopologyCache(config.TopologyLabels)
+	}
+	if config.EnableTraceroute {
+		config.traces = newTraceManager(config)
+	}
+	if config.EnablePMTU {
+		config.pmtu = newPMTUManager(config)
+	}
+	if config.EnableMesh {
+		selector, err := newMeshSelector(config)
+		if err != nil {
+			return err
+		}
+		config.meshSelector = selector
+	}
+	if opts.KubeClient == nil || opts.NetworkClient == nil {
+		if err := config.initKubeClient(); err != nil {
+			return err
+		}
+	}
+	if opts.KubeClient != nil {
+		config.KubeClient = opts.KubeClient
+	}
+	if opts.NetworkClient != nil {
+		config.NetworkClient = opts.NetworkClient
+	}
+	if config.EnableEvents {
+		config.initEventRecorder()
+	}
+	if err := config.initNotifier(); err != nil {
+		return err
+	}
+	if config.EnableHistory {
+		config.history = newHistoryStore(config)
+		// a history that cannot be restored starts empty
+		_ = config.history.load()
+	}
+	if config.OTLPEndpoint != "" {
+		exporter, err := newOTelExporter(config)
+		if err != nil {
+			return err
+		}
+		config.otel = exporter
+	}
+	if err := config.initSinks(opts.Sinks); err != nil {
+		return err
+	}
+	config.gatherer = prometheus.DefaultGatherer
+	if gatherer, ok := opts.Registerer.(prometheus.Gatherer); ok {
+		config.gatherer = gatherer
+	}
+	return nil
+}
+
 func (config *Configuration) initKubeClient() error {
 	var cfg *rest.Config
 	var err error
@@ -169,4 +788,60 @@ func (config *Configuration) initKubeClient() error {
 	config.NetworkClient = networkClient
 
 	return nil
-}
\ No newline at end of file
+}
+
+// initNetworkInformers prepares the mec.io informers, the listers are usable once the factory
+// has been started and the caches are synced.
+func (config *Configuration) initNetworkInformers() {
+	config.networkInformerFactory = networkInformer.NewSharedInformerFactory(config.NetworkClient, 0)
+	ipInformer := config.networkInformerFactory.Mec().V1().IPs()
+	config.IPLister = ipInformer.Lister()
+	config.ipSynced = ipInformer.Informer().HasSynced
+	if config.EnableSubnetMetrics {
+		subnetInformer := config.networkInformerFactory.Mec().V1().Subnets()
+		config.SubnetLister = subnetInformer.Lister()
+		config.subnetSynced = subnetInformer.Informer().HasSynced
+	}
+	if config.EnablePingTargets {
+		config.pingTargetLister = config.networkInformerFactory.Mec().V1().PingTargets().Lister()
+	}
+}
+
+// initKubeInformers prepares the node and pod informers shared by the checks, the mesh peer
+// selection and the ping targets, and the daemonset informer of the mesh, so the checks do not
+// list them from the apiserver every cycle.
+func (config *Configuration) initKubeInformers() {
+	config.kubeInformerFactory = informers.NewSharedInformerFactory(config.KubeClient, 0)
+	config.nodeLister = config.kubeInformerFactory.Core().V1().Nodes().Lister()
+	config.podLister = config.kubeInformerFactory.Core().V1().Pods().Lister()
+	if config.EnableMesh {
+		config.daemonSetLister = config.kubeInformerFactory.Apps().V1().DaemonSets().Lister()
+	}
+}
+
+func (config *Configuration) networkInformersSynced() bool {
+	return config.ipSynced != nil && config.ipSynced() && config.subnetSynced != nil && config.subnetSynced()
+}
+
+func (config *Configuration) initNotifier() error {
+	if len(config.Webhooks) == 0 {
+		return nil
+	}
+	webhooks := make([]*notifier.Webhook, 0, len(config.Webhooks))
+	for _, value := range config.Webhooks {
+		webhook, err := notifier.ParseWebhook(value)
+		if err != nil {
+			klog.Errorf("failed to parse webhook: %v", err)
+			return err
+		}
+		if config.WebhookTemplate != "" && webhook.Format == notifier.FormatJSON {
+			if err = webhook.SetTemplateFile(config.WebhookTemplate); err != nil {
+				klog.Errorf("failed to load webhook template %s: %v", config.WebhookTemplate, err)
+				return err
+			}
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	config.notifier = notifier.NewNotifier(config.IncidentPolicy, webhooks)
+	return nil
+}
@@ -0,0 +1,463 @@
+package pinger
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// probeProfilesKey holds the probe profiles in the configuration file, every other key is a flag name.
+const probeProfilesKey = "probe-profiles"
+
+// redactedValue replaces credentials in the logs.
+const redactedValue = "<redacted>"
+
+// secretFlags hold credentials, their values are never logged.
+var secretFlags = map[string]bool{
+	"otlp-headers": true,
+	"influx-token": true,
+}
+
+// notInConfigFile are the flags that choose the configuration file itself.
+var notInConfigFile = map[string]bool{
+	"config":        true,
+	"config-schema": true,
+}
+
+// reloadableFlags are read by every probe cycle, so a new value applies from the next cycle on.
+// The other flags set up clients, informers and background workers once and need a restart.
+var reloadableFlags = map[string]bool{
+	"interval":            true,
+	"dest-namespace":      true,
+	"internal-dns":        true,
+	"external-dns":        true,
+	"external-address":    true,
+	"external-subnet":     true,
+	"enable-ip-audit":     true,
+	"ip-audit-report":     true,
+	"enable-ping-result":  true,
+	"check-policy":        true,
+	"max-loss-percent":    true,
+	"max-rtt-ms":          true,
+	"probe-profiles-file": true,
+	"probe-profile":       true,
+	"dscp-classes":        true,
+	"v":                   true,
+	"vmodule":             true,
+}
+
+// flagKind is the json type of the flag in the configuration file and, for arrays, of its items.
+func flagKind(f *pflag.Flag) (string, string) {
+	switch f.Value.Type() {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "Level":
+		return "integer", ""
+	case "float32", "float64":
+		return "number", ""
+	case "bool":
+		return "boolean", ""
+	case "string", "duration":
+		return "string", ""
+	case "stringSlice", "stringArray":
+		return "array", "string"
+	case "intSlice", "int32Slice", "int64Slice", "uintSlice":
+		return "array", "integer"
+	case "stringToString":
+		return "object", "string"
+	case "stringToInt":
+		return "object", "integer"
+	}
+	// flags of go libraries, like the klog ones, only tell their own type name
+	return "scalar", ""
+}
+
+// configSchema describes the configuration file as a json schema generated from the flags.
+func configSchema(fs *pflag.FlagSet) ([]byte, error) {
+	properties := make(map[string]interface{})
+	fs.VisitAll(func(f *pflag.Flag) {
+		if notInConfigFile[f.Name] {
+			return
+		}
+		property := map[string]interface{}{"description": f.Usage}
+		kind, item := flagKind(f)
+		switch kind {
+		case "array":
+			property["type"] = kind
+			property["items"] = map[string]interface{}{"type": item}
+		case "object":
+			property["type"] = kind
+			property["additionalProperties"] = map[string]interface{}{"type": item}
+		case "scalar":
+			property["type"] = []string{"string", "number", "boolean"}
+		default:
+			property["type"] = kind
+		}
+		if f.Value.Type() == "duration" {
+			property["pattern"] = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+		}
+		properties[f.Name] = property
+	})
+
+	profile := map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"count":    map[string]interface{}{"type": "integer", "minimum": 1},
+			"interval": map[string]interface{}{"type": "string"},
+			"timeout":  map[string]interface{}{"type": "string"},
+			"size":     map[string]interface{}{"type": "integer", "minimum": minProbeSize, "maximum": maxProbeSize},
+			"ttl":      map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 255},
+			"tos":      map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 255},
+		},
+	}
+	profiles := make(map[string]interface{})
+	for class := range defaultProbeProfiles() {
+		profiles[class] = profile
+	}
+	properties[probeProfilesKey] = map[string]interface{}{
+		"description":          "Probe profile of each target class, between --probe-profiles-file and --probe-profile",
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           profiles,
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"title":                "network-pinger configuration",
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           properties,
+	}, "", "  ")
+}
+
+// loadConfigFile validates the configuration file against the flags and sets every flag
+// that was not given on the command line from it.
+func (f *configFlags) loadConfigFile(fs *pflag.FlagSet) error {
+	path := *f.ConfigFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration file: %v", err)
+	}
+	values := make(map[string]interface{})
+	if err = yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("failed to parse configuration file %s: %v", path, err)
+	}
+
+	for key, value := range values {
+		if key == probeProfilesKey {
+			raw, err := json.Marshal(value)
+			if err == nil {
+				f.fileProbeProfiles = make(map[string]ProbeProfile)
+				err = yaml.UnmarshalStrict(raw, &f.fileProbeProfiles)
+			}
+			if err != nil {
+				return fmt.Errorf("invalid %s in configuration file %s: %v", key, path, err)
+			}
+			continue
+		}
+
+		flag := fs.Lookup(key)
+		if flag == nil || notInConfigFile[key] {
+			return fmt.Errorf("unknown key %s in configuration file %s", key, path)
+		}
+		set, err := flagSetter(flag, value)
+		if err != nil {
+			return fmt.Errorf("invalid %s in configuration file %s: %v", key, path, err)
+		}
+		if flag.Changed {
+			klog.V(3).Infof("flag --%s overrides %s of the configuration file", key, key)
+			continue
+		}
+		if err = set(); err != nil {
+			return fmt.Errorf("invalid %s in configuration file %s: %v", key, path, err)
+		}
+	}
+	return nil
+}
+
+// flagSetter checks the value has the json type of the flag and returns how to set it without
+// marking the flag as changed, which only flags given on the command line are.
+func flagSetter(f *pflag.Flag, value interface{}) (func() error, error) {
+	kind, item := flagKind(f)
+	switch kind {
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected an array")
+		}
+		items := make([]string, 0, len(list))
+		for _, v := range list {
+			s, err := scalarString(item, v)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, s)
+		}
+		sliceValue, ok := f.Value.(pflag.SliceValue)
+		if !ok {
+			return nil, fmt.Errorf("flag --%s does not take a list", f.Name)
+		}
+		return func() error { return sliceValue.Replace(items) }, nil
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected an object")
+		}
+		keys := make([]string, 0, len(object))
+		for k := range object {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, 0, len(object))
+		for _, k := range keys {
+			s, err := scalarString(item, object[k])
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, k+"="+s)
+		}
+		if len(pairs) == 0 {
+			return func() error { return nil }, nil
+		}
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		if err := w.Write(pairs); err != nil {
+			return nil, err
+		}
+		w.Flush()
+		return func() error { return f.Value.Set(strings.TrimSuffix(buf.String(), "\n")) }, nil
+	default:
+		s, err := scalarString(kind, value)
+		if err != nil {
+			return nil, err
+		}
+		return func() error { return f.Value.Set(s) }, nil
+	}
+}
+
+func scalarString(kind string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		if kind == "string" || kind == "scalar" {
+			return v, nil
+		}
+	case bool:
+		if kind == "boolean" || kind == "scalar" {
+			return strconv.FormatBool(v), nil
+		}
+	case float64:
+		if kind == "integer" && v == math.Trunc(v) || kind == "number" || kind == "scalar" {
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+	}
+	return "", fmt.Errorf("expected a value of type %s, got %v", kind, value)
+}
+
+func flagValues(fs *pflag.FlagSet) map[string]string {
+	values := make(map[string]string)
+	fs.VisitAll(func(f *pflag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+	return values
+}
+
+// configReloader checks the configuration file for changes, which is polled rather than watched
+// because a mounted ConfigMap is swapped through symlinks. The configuration built from a valid
+// change waits in pending until the probe loop applies it between two cycles.
+type configReloader struct {
+	path     string
+	interval time.Duration
+	digest   [sha256.Size]byte
+	values   map[string]string
+
+	mu      sync.Mutex
+	pending *Configuration
+}
+
+func newConfigReloader(path string, interval time.Duration, fs *pflag.FlagSet) (*configReloader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file: %v", err)
+	}
+	return &configReloader{path: path, interval: interval, digest: sha256.Sum256(data), values: flagValues(fs)}, nil
+}
+
+func (r *configReloader) run(stopCh <-chan struct{}) {
+	go wait.Until(r.check, r.interval, stopCh)
+}
+
+func (r *configReloader) check() {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		klog.Errorf("failed to read configuration file %s: %v", r.path, err)
+		return
+	}
+	digest := sha256.Sum256(data)
+	if digest == r.digest {
+		return
+	}
+	r.digest = digest
+	klog.Infof("configuration file %s changed, reload it", r.path)
+
+	// the command line is parsed again on flags of their own, so the flags the new file
+	// does not set any more fall back to their defaults
+	fs := pflag.NewFlagSet("network-pinger", pflag.ContinueOnError)
+	fs.ParseErrorsWhitelist.UnknownFlags = true
+	args := registerFlags(fs)
+	klogFlags := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(klogFlags)
+	fs.AddGoFlagSet(klogFlags)
+	if err = fs.Parse(os.Args[1:]); err != nil {
+		klog.Errorf("failed to parse flags for the reload: %v", err)
+		return
+	}
+	if err = args.loadConfigFile(fs); err != nil {
+		klog.Errorf("keep the running configuration: %v", err)
+		return
+	}
+	next, err := args.configuration()
+	if err != nil {
+		klog.Errorf("keep the running configuration, invalid configuration file %s: %v", r.path, err)
+		return
+	}
+
+	values := flagValues(fs)
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, old := values[name], r.values[name]
+		if _, ok := r.values[name]; !ok || value == old {
+			continue
+		}
+		if secretFlags[name] {
+			value, old = redactedValue, redactedValue
+		}
+		if reloadableFlags[name] {
+			klog.Infof("reload %s: %s -> %s", name, old, value)
+		} else {
+			klog.Warningf("changing %s from %s to %s requires a restart, keep the running value", name, old, value)
+		}
+	}
+	r.values = values
+
+	r.mu.Lock()
+	r.pending = next
+	r.mu.Unlock()
+}
+
+// next returns the configuration reloaded since the last call, nil if there is none.
+func (r *configReloader) next() *Configuration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	next := r.pending
+	r.pending = nil
+	return next
+}
+
+// reload applies the reloadable settings of the next configuration. Nothing is reset, so the
+// metrics of the targets that did not change go on in the same series.
+func (config *Configuration) reload(next *Configuration) {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	config.Interval = next.Interval
+	config.DestNamespace = next.DestNamespace
+	config.InternalDNS = next.InternalDNS
+	config.ExternalDNS = next.ExternalDNS
+	config.ExternalAddress = next.ExternalAddress
+	config.ExternalSubnet = next.ExternalSubnet
+	config.EnableIPAudit = next.EnableIPAudit
+	config.IPAuditReport = next.IPAuditReport
+	config.EnablePingResult = next.EnablePingResult
+	config.CheckPolicies = next.CheckPolicies
+	config.MaxLossPercent = next.MaxLossPercent
+	config.MaxRTTMilliseconds = next.MaxRTTMilliseconds
+	config.ProbeProfiles = next.ProbeProfiles
+	config.DSCPClasses = next.DSCPClasses
+}
+
+// The accessors below read the reloadable settings under the read lock, the probe cycle, the
+// target workers, the background managers and the http handlers never read the fields directly.
+
+// probeProfile returns the profile of the target class.
+func (config *Configuration) probeProfile(class string) ProbeProfile {
+	config.mu.RLock()
+	defer config.mu.RUnlock()
+	return config.ProbeProfiles[class]
+}
+
+func (config *Configuration) dscpClasses() []int {
+	config.mu.RLock()
+	defer config.mu.RUnlock()
+	return config.DSCPClasses
+}
+
+func (config *Configuration) interval() time.Duration {
+	config.mu.RLock()
+	defer config.mu.RUnlock()
+	return time.Duration(config.Interval) * time.Second
+}
+
+func (config *Configuration) destNamespace() string {
+	config.mu.RLock()
+	defer config.mu.RUnlock()
+	return config.DestNamespace
+}
+
+func (config *Configuration) internalDNS() string {
+	config.mu.RLock()
+	defer config.mu.RUnlock()
+	return config.InternalDNS
+}
+
+func (config *Configuration) externalDNS() string {
+	config.mu.RLock()
+	defer config.mu.RUnlock()
+	return config.ExternalDNS
+}
+
+func (config *Configuration) externalAddress() string {
+	config.mu.RLock()
+	defer config.mu.RUnlock()
+	return config.ExternalAddress
+}
+
+func (config *Configuration) externalSubnet() string {
+	config.mu.RLock()
+	defer config.mu.RUnlock()
+	return config.ExternalSubnet
+}
+
+func (config *Configuration) ipAuditEnabled() bool {
+	config.mu.RLock()
+	defer config.mu.RUnlock()
+	return config.EnableIPAudit
+}
+
+func (config *Configuration) ipAuditReport() string {
+	config.mu.RLock()
+	defer config.mu.RUnlock()
+	return config.IPAuditReport
+}
+
+func (config *Configuration) pingResultEnabled() bool {
+	config.mu.RLock()
+	defer config.mu.RUnlock()
+	return config.EnablePingResult
+}
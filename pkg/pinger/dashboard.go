@@ -0,0 +1,254 @@
+package pinger
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	networkv1 "pkg/apis/network/v1"
+)
+
+const (
+	ClusterSourcePingResults = "pingresults"
+	ClusterSourceLocal       = "local"
+
+	// peerRequestTimeout bounds the requests to the api server and to the pinger of another
+	// node the dashboard apis make on behalf of the browser.
+	peerRequestTimeout = 10 * time.Second
+)
+
+//go:embed dashboard
+var dashboardAssets embed.FS
+
+// DashboardHandler serves the web dashboard, it is mounted under /dashboard/ and reads the
+// cluster, history and outages apis of the same server.
+func DashboardHandler() http.Handler {
+	assets, err := fs.Sub(dashboardAssets, "dashboard")
+	if err != nil {
+		// the directory is embedded at build time
+		panic(err)
+	}
+	return http.StripPrefix("/dashboard/", http.FileServer(http.FS(assets)))
+}
+
+// ClusterView is the latest result of every target seen from every node, read from the
+// PingResults of the nodes or, when they are not published, from this node only.
+type ClusterView struct {
+	LocalNode string        `json:"localNode"`
+	Source    string        `json:"source"`
+	Error     string        `json:"error,omitempty"`
+	Nodes     []ClusterNode `json:"nodes"`
+}
+
+// ClusterNode is the latest result of the targets probed from a node, Stale is set when
+// its pinger has not published for longer than the results are remembered. OmittedTargets
+// counts the targets its PingResult had no room for.
+type ClusterNode struct {
+	NodeName       string            `json:"nodeName"`
+	NodeIP         string            `json:"nodeIP,omitempty"`
+	PodName        string            `json:"podName,omitempty"`
+	Topology       map[string]string `json:"topology,omitempty"`
+	LastUpdateTime time.Time         `json:"lastUpdateTime"`
+	Stale          bool              `json:"stale"`
+	Targets        []ClusterTarget   `json:"targets"`
+	OmittedTargets int               `json:"omittedTargets,omitempty"`
+}
+
+// ClusterTarget is the latest result of a target, Key is the target parameter of the history
+// and outages apis.
+type ClusterTarget struct {
+	Key           string        `json:"key"`
+	Check         string        `json:"check"`
+	Name          string        `json:"name,omitempty"`
+	Namespace     string        `json:"namespace,omitempty"`
+	NodeName      string        `json:"nodeName,omitempty"`
+	Address       string        `json:"address,omitempty"`
+	Reachable     bool          `json:"reachable"`
+	Sent          int           `json:"sent"`
+	Lost          int           `json:"lost"`
+	AvgRTT        time.Duration `json:"avgRtt"`
+	Error         string        `json:"error,omitempty"`
+	LastProbeTime time.Time     `json:"lastProbeTime"`
+}
+
+// pingResultCache keeps the PingResults listed for the cluster view for a probe interval, the
+// pingers publish at most once per interval, so the polls of every open dashboard share a list.
+type pingResultCache struct {
+	mu      sync.Mutex
+	items   []networkv1.PingResult
+	fetched time.Time
+}
+
+// listPingResults returns the cached PingResults, listing them again once they are older than
+// the probe interval. Concurrent polls wait for the same list.
+func (config *Configuration) listPingResults(ctx context.Context) ([]networkv1.PingResult, error) {
+	c := &config.pingResults
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.fetched.IsZero() && time.Since(c.fetched) < config.interval() {
+		return c.items, nil
+	}
+	results, err := config.NetworkClient.MecV1().PingResults().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	c.items, c.fetched = results.Items, time.Now()
+	return c.items, nil
+}
+
+// ClusterHandler serves the cluster view the heatmaps of the dashboard are drawn from.
+func (config *Configuration) ClusterHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx, cancel := context.WithTimeout(req.Context(), peerRequestTimeout)
+		defer cancel()
+		writeJSON(w, config.clusterView(ctx))
+	})
+}
+
+// clusterView merges the PingResults of the other nodes with the live results of this node,
+// a failed list falls back to this node and tells why.
+func (config *Configuration) clusterView(ctx context.Context) ClusterView {
+	view := ClusterView{LocalNode: config.NodeName, Source: ClusterSourceLocal}
+	if config.pingResultEnabled() {
+		results, err := config.listPingResults(ctx)
+		if err != nil {
+			klog.Errorf("failed to list ping results: %v", err)
+			view.Error = fmt.Sprintf("failed to list ping results, only the results of this node are shown: %v", err)
+		} else {
+			view.Source = ClusterSourcePingResults
+			// a status is only rewritten on changes and every heartbeat
+			ttl := config.resultTTL() + config.PingResultHeartbeat
+			for _, result := range results {
+				if result.Spec.NodeName == config.NodeName {
+					continue
+				}
+				node := ClusterNode{
+					NodeName:       result.Spec.NodeName,
+					NodeIP:         result.Spec.NodeIP,
+					PodName:        result.Spec.PodName,
+					Topology:       result.Spec.Topology,
+					LastUpdateTime: result.Status.LastUpdateTime.Time,
+					Stale:          time.Since(result.Status.LastUpdateTime.Time) > ttl,
+					Targets:        make([]ClusterTarget, 0, len(result.Status.Targets)),
+					OmittedTargets: int(result.Status.OmittedTargets),
+				}
+				for _, t := range result.Status.Targets {
+					node.Targets = append(node.Targets, ClusterTarget{
+						Key:           ProbeResult{Check: t.Check, Namespace: t.Namespace, Name: t.Name, Address: t.Address}.Key(),
+						Check:         t.Check,
+						Name:          t.Name,
+						Namespace:     t.Namespace,
+						NodeName:      t.NodeName,
+						Address:       t.Address,
+						Reachable:     t.Reachable,
+						Sent:          int(t.Sent),
+						Lost:          int(t.Lost),
+						AvgRTT:        t.AvgRTT.Duration,
+						Error:         t.Error,
+						LastProbeTime: t.LastProbeTime.Time,
+					})
+				}
+				view.Nodes = append(view.Nodes, node)
+			}
+		}
+	}
+
+	local := ClusterNode{
+		NodeName:       config.NodeName,
+		NodeIP:         config.HostIP,
+		PodName:        config.PodName,
+		Topology:       config.topology.node(config.NodeName),
+		LastUpdateTime: time.Now(),
+	}
+	for _, state := range config.results.snapshot(config.resultTTL()) {
+		local.Targets = append(local.Targets, ClusterTarget{
+			Key:           state.Key(),
+			Check:         state.Check,
+			Name:          state.Name,
+			Namespace:     state.Namespace,
+			NodeName:      state.NodeName,
+			Address:       state.Address,
+			Reachable:     state.Healthy,
+			Sent:          state.Sent,
+			Lost:          state.Lost,
+			AvgRTT:        state.AvgRTT,
+			Error:         state.Error,
+			LastProbeTime: state.Timestamp,
+		})
+	}
+	view.Nodes = append(view.Nodes, local)
+	sort.Slice(view.Nodes, func(i, j int) bool {
+		return view.Nodes[i].NodeName < view.Nodes[j].NodeName
+	})
+	return view
+}
+
+// proxyToNode forwards the request to the pinger of the node, found through the pod name of
+// the PingResult of the node, without its node parameter.
+func (config *Configuration) proxyToNode(w http.ResponseWriter, req *http.Request, nodeName string) {
+	if !config.pingResultEnabled() {
+		http.Error(w, "the results of other nodes need --enable-ping-result", http.StatusNotFound)
+		return
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), peerRequestTimeout)
+	defer cancel()
+	result, err := config.NetworkClient.MecV1().PingResults().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			http.Error(w, fmt.Sprintf("node %s publishes no ping result", nodeName), http.StatusNotFound)
+			return
+		}
+		klog.Errorf("failed to get ping result %s: %v", nodeName, err)
+		http.Error(w, fmt.Sprintf("failed to find the pinger of node %s: %v", nodeName, err), http.StatusBadGateway)
+		return
+	}
+	pod, err := config.KubeClient.CoreV1().Pods(config.DaemonSetNamespace).Get(ctx, result.Spec.PodName, metav1.GetOptions{})
+	if err != nil {
+		klog.Errorf("failed to get pinger pod %s/%s: %v", config.DaemonSetNamespace, result.Spec.PodName, err)
+		http.Error(w, fmt.Sprintf("failed to find the pinger of node %s: %v", nodeName, err), http.StatusBadGateway)
+		return
+	}
+	if pod.Status.PodIP == "" {
+		http.Error(w, fmt.Sprintf("pinger pod %s of node %s has no ip", pod.Name, nodeName), http.StatusBadGateway)
+		return
+	}
+
+	query := req.URL.Query()
+	query.Del("node")
+	target := url.URL{
+		Scheme:   "http",
+		Host:     net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(config.Port)),
+		Path:     req.URL.Path,
+		RawQuery: query.Encode(),
+	}
+	peerReq, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp, err := http.DefaultClient.Do(peerReq)
+	if err != nil {
+		klog.Errorf("failed to query the pinger of node %s: %v", nodeName, err)
+		http.Error(w, fmt.Sprintf("failed to query the pinger of node %s: %v", nodeName, err), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	if _, err = io.Copy(w, resp.Body); err != nil {
+		klog.Errorf("failed to forward the answer of the pinger of node %s: %v", nodeName, err)
+	}
+}
@@ -0,0 +1,483 @@
+"use strict";
+
+// the apis are resolved against the page so the dashboard also works behind kubectl proxy
+const api = (path, params) => {
+  const url = new URL("../api/v1/" + path, window.location.href);
+  Object.entries(params || {}).forEach(([k, v]) => v && url.searchParams.set(k, v));
+  return url;
+};
+
+const LOSS_SCALE = [
+  { max: 0, color: "var(--ok)", label: "no loss" },
+  { max: 0.01, color: "var(--good)", label: "< 1%" },
+  { max: 0.05, color: "var(--warn)", label: "< 5%" },
+  { max: 0.2, color: "var(--bad)", label: "< 20%" },
+  { max: Infinity, color: "var(--down)", label: "≥ 20% or unreachable" },
+];
+
+const RTT_SCALE = [
+  { max: 1, color: "var(--ok)", label: "< 1ms" },
+  { max: 5, color: "var(--good)", label: "< 5ms" },
+  { max: 20, color: "var(--warn)", label: "< 20ms" },
+  { max: 100, color: "var(--bad)", label: "< 100ms" },
+  { max: Infinity, color: "var(--down)", label: "≥ 100ms or unreachable" },
+];
+
+const state = {
+  view: null,
+  tab: "node",
+  metric: "loss",
+  timer: null,
+  selected: null,
+};
+
+const $ = (id) => document.getElementById(id);
+
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  Object.entries(attrs || {}).forEach(([k, v]) => {
+    if (v === undefined || v === null || v === false) {
+      return;
+    }
+    if (k.startsWith("on")) {
+      node.addEventListener(k.slice(2), v);
+    } else if (k === "class") {
+      node.className = v;
+    } else {
+      node.setAttribute(k, v);
+    }
+  });
+  children.flat().forEach((child) => {
+    if (child !== undefined && child !== null) {
+      node.append(child instanceof Node ? child : String(child));
+    }
+  });
+  return node;
+}
+
+const ms = (ns) => ns / 1e6;
+const fmtMs = (ns) => (ns > 0 ? ms(ns).toFixed(ms(ns) < 10 ? 2 : 1) + "ms" : "-");
+const fmtPct = (ratio) => (ratio * 100).toFixed(ratio < 0.1 ? 1 : 0) + "%";
+const fmtTime = (t) => new Date(t).toLocaleString();
+
+function fmtDuration(ns) {
+  let s = Math.round(ns / 1e9);
+  const parts = [];
+  [["d", 86400], ["h", 3600], ["m", 60]].forEach(([unit, size]) => {
+    if (s >= size) {
+      parts.push(Math.floor(s / size) + unit);
+      s %= size;
+    }
+  });
+  if (s || !parts.length) {
+    parts.push(s + "s");
+  }
+  return parts.slice(0, 2).join(" ");
+}
+
+function showError(id, message) {
+  const banner = $(id);
+  banner.hidden = !message;
+  banner.textContent = message || "";
+}
+
+async function fetchJSON(url) {
+  const resp = await fetch(url, { headers: { Accept: "application/json" } });
+  if (!resp.ok) {
+    throw new Error((await resp.text()).trim() || resp.statusText);
+  }
+  return resp.json();
+}
+
+// cell merges the results of the addresses of a target, or of the targets of a node
+function newCell() {
+  return { sent: 0, lost: 0, rttSum: 0, rttCount: 0, unreachable: 0, targets: [] };
+}
+
+function addToCell(cell, target) {
+  cell.targets.push(target);
+  cell.sent += target.sent;
+  cell.lost += target.lost;
+  if (target.avgRtt > 0) {
+    cell.rttSum += target.avgRtt;
+    cell.rttCount++;
+  }
+  if (!target.reachable) {
+    cell.unreachable++;
+  }
+}
+
+function cellLoss(cell) {
+  if (cell.unreachable && !cell.sent) {
+    return 1;
+  }
+  return cell.sent ? cell.lost / cell.sent : cell.unreachable / cell.targets.length;
+}
+
+function cellRtt(cell) {
+  return cell.rttCount ? cell.rttSum / cell.rttCount : 0;
+}
+
+function cellColor(cell) {
+  if (state.metric === "loss") {
+    const loss = cellLoss(cell);
+    return LOSS_SCALE.find((s) => loss <= s.max).color;
+  }
+  if (cell.unreachable === cell.targets.length) {
+    return "var(--down)";
+  }
+  const rtt = ms(cellRtt(cell));
+  return RTT_SCALE.find((s) => rtt < s.max).color;
+}
+
+function cellTitle(source, column, cell) {
+  const lines = [`${source} → ${column}`];
+  cell.targets.forEach((t) => {
+    let line = `${t.address || t.name}: `;
+    line += t.reachable ? `${t.sent - t.lost}/${t.sent} replies, rtt ${fmtMs(t.avgRtt)}` : `unreachable`;
+    if (t.error) {
+      line += ` (${t.error})`;
+    }
+    lines.push(line);
+  });
+  lines.push("probed " + fmtTime(cell.targets[0].lastProbeTime));
+  return lines.join("\n");
+}
+
+// buildMatrix returns a row per probing node and the probed nodes or pods of the tab as columns,
+// the pods grouped by their node
+function buildMatrix(view) {
+  const columns = new Map();
+  const rows = view.nodes.map((node) => {
+    const cells = new Map();
+    (node.targets || []).forEach((t) => {
+      if (t.check !== state.tab) {
+        return;
+      }
+      let id;
+      let group = "";
+      if (state.tab === "node") {
+        id = t.nodeName || t.name;
+      } else {
+        id = t.namespace ? `${t.namespace}/${t.name}` : t.name;
+        group = t.nodeName || "";
+      }
+      if (!columns.has(id)) {
+        columns.set(id, { id, group, label: state.tab === "node" ? id : t.name });
+      }
+      if (!cells.has(id)) {
+        cells.set(id, newCell());
+      }
+      addToCell(cells.get(id), t);
+    });
+    return { node, cells };
+  });
+  const sorted = [...columns.values()].sort((a, b) => a.group.localeCompare(b.group) || a.id.localeCompare(b.id));
+  return { rows, columns: sorted };
+}
+
+function renderLegend() {
+  const scale = state.metric === "loss" ? LOSS_SCALE : RTT_SCALE;
+  const legend = $("legend");
+  legend.replaceChildren(
+    ...scale.map((s) => el("span", { style: `--swatch: ${s.color}` }, s.label)),
+    el("span", { style: "--swatch: var(--none)" }, "not probed"),
+  );
+}
+
+function renderHeatmap() {
+  const table = $("heatmap");
+  const { rows, columns } = buildMatrix(state.view);
+  if (!columns.length) {
+    table.replaceChildren(el("tbody", null, el("tr", null, el("td", { class: "empty" }, `no ${state.tab} results`))));
+    return;
+  }
+
+  const head = el("thead");
+  if (state.tab === "pod") {
+    const groups = el("tr", null, el("th"));
+    let previous = null;
+    columns.forEach((c) => {
+      if (previous && previous.group === c.group) {
+        previous.cell.colSpan++;
+        return;
+      }
+      previous = { group: c.group, cell: el("th", { class: "group" }, c.group || "-") };
+      groups.append(previous.cell);
+    });
+    head.append(groups);
+  }
+  head.append(el("tr", null, el("th"), columns.map((c) => el("th", { class: "col", title: c.id }, el("div", null, c.label)))));
+
+  const body = el("tbody");
+  rows.forEach(({ node, cells }) => {
+    let title = node.stale ? `no result published since ${fmtTime(node.lastUpdateTime)}` : node.nodeIP;
+    if (node.omittedTargets) {
+      title += `, ${node.omittedTargets} targets not published`;
+    }
+    const header = el("th", { class: node.stale ? "stale" : null, title }, node.nodeName);
+    const tr = el("tr", null, header);
+    columns.forEach((c) => {
+      const cell = cells.get(c.id);
+      if (!cell) {
+        const self = state.tab === "node" && c.id === node.nodeName;
+        tr.append(el("td", { class: self ? "empty self" : "empty" }));
+        return;
+      }
+      const selected = state.selected && state.selected.node === node.nodeName && state.selected.column === c.id;
+      tr.append(el("td", {
+        class: selected ? "selected" : null,
+        style: `background: ${cellColor(cell)}`,
+        title: cellTitle(node.nodeName, c.id, cell),
+        onclick: () => openDetails(node.nodeName, c.id, cell.targets),
+      }, cell.unreachable ? "×" : ""));
+    });
+    body.append(tr);
+  });
+  table.replaceChildren(head, body);
+}
+
+// serviceCard summarises a check every node runs against a shared service
+function serviceCard(title, check) {
+  const results = [];
+  state.view.nodes.forEach((node) => {
+    (node.targets || []).filter((t) => t.check === check).forEach((t) => results.push({ node: node.nodeName, t }));
+  });
+  if (!results.length) {
+    return el("div", { class: "card" }, el("h2", null, title), el("div", { class: "value" }, "-"), el("div", null, "not probed"));
+  }
+  const failed = results.filter((r) => !r.t.reachable);
+  const worst = Math.max(...results.filter((r) => r.t.reachable).map((r) => r.t.avgRtt), 0);
+  let level = "ok";
+  if (failed.length === results.length) {
+    level = "down";
+  } else if (failed.length) {
+    level = "warn";
+  }
+  return el("div", { class: `card ${level}` },
+    el("h2", null, title),
+    el("div", { class: "value" }, `${results.length - failed.length}/${results.length}`),
+    el("div", null, `nodes healthy, slowest ${fmtMs(worst)}`),
+    failed.length ? el("ul", null, failed.slice(0, 5).map((r) => el("li", null, `${r.node}: ${r.t.error || "unhealthy"}`))) : null,
+  );
+}
+
+function reachabilityCard(title, check) {
+  let total = 0;
+  let down = 0;
+  state.view.nodes.forEach((node) => {
+    (node.targets || []).filter((t) => t.check === check).forEach((t) => {
+      total++;
+      if (!t.reachable) {
+        down++;
+      }
+    });
+  });
+  let level = total ? "ok" : "";
+  if (down) {
+    level = down === total ? "down" : "warn";
+  }
+  return el("div", { class: `card ${level}` },
+    el("h2", null, title),
+    el("div", { class: "value" }, total ? `${total - down}/${total}` : "-"),
+    el("div", null, total ? "probes reachable" : "not probed"),
+  );
+}
+
+function pingersCard() {
+  const nodes = state.view.nodes;
+  const stale = nodes.filter((n) => n.stale);
+  return el("div", { class: `card ${stale.length ? "warn" : "ok"}` },
+    el("h2", null, "Pingers"),
+    el("div", { class: "value" }, `${nodes.length - stale.length}/${nodes.length}`),
+    el("div", null, state.view.source === "local" ? "this node only, enable --enable-ping-result for the cluster" : "nodes reporting"),
+    stale.length ? el("ul", null, stale.slice(0, 5).map((n) => el("li", null, `${n.nodeName}: silent since ${fmtTime(n.lastUpdateTime)}`))) : null,
+  );
+}
+
+function renderCards() {
+  $("cards").replaceChildren(
+    serviceCard("API server", "apiserver"),
+    serviceCard("DNS", "dns"),
+    serviceCard("External DNS", "externaldns"),
+    serviceCard("External", "external"),
+    reachabilityCard("Nodes", "node"),
+    reachabilityCard("Pods", "pod"),
+    pingersCard(),
+  );
+}
+
+function render() {
+  if (!state.view) {
+    return;
+  }
+  $("summary").textContent = `served by ${state.view.localNode}, updated ${new Date().toLocaleTimeString()}`;
+  renderCards();
+  renderLegend();
+  renderHeatmap();
+}
+
+async function refresh() {
+  try {
+    state.view = await fetchJSON(api("cluster"));
+    showError("error", state.view.error);
+    render();
+  } catch (err) {
+    showError("error", `failed to load the results: ${err.message}`);
+  }
+}
+
+function schedule() {
+  clearInterval(state.timer);
+  const seconds = Number($("refresh").value);
+  if (seconds > 0) {
+    state.timer = setInterval(() => {
+      refresh();
+      if (state.selected) {
+        loadDetails();
+      }
+    }, seconds * 1000);
+  }
+}
+
+function openDetails(node, column, targets) {
+  state.selected = { node, column, targets, key: targets[0].key };
+  $("details").hidden = false;
+  renderHeatmap();
+  loadDetails();
+  $("details").scrollIntoView({ behavior: "smooth" });
+}
+
+function renderChips() {
+  const { targets, key } = state.selected;
+  $("details-targets").replaceChildren(...(targets.length > 1 ? targets.map((t) => el("button", {
+    type: "button",
+    class: t.key === key ? "active" : null,
+    onclick: () => {
+      state.selected.key = t.key;
+      loadDetails();
+    },
+  }, t.address || t.name)) : []));
+}
+
+async function loadDetails() {
+  const selected = state.selected;
+  const target = selected.targets.find((t) => t.key === selected.key);
+  $("details-title").textContent = `${selected.node} → ${selected.column}` + (target.address ? ` (${target.address})` : "");
+  renderChips();
+  const params = { node: selected.node, target: selected.key, since: $("range").value };
+  try {
+    const [history, outages] = await Promise.all([fetchJSON(api("history", params)), fetchJSON(api("outages", params))]);
+    if (state.selected !== selected || selected.key !== params.target) {
+      return;
+    }
+    showError("details-error", "");
+    renderChart(history.length ? history[0].points : []);
+    renderOutages(outages.length ? outages[0].outages : []);
+  } catch (err) {
+    showError("details-error", `failed to load the history: ${err.message}`);
+    $("chart").replaceChildren();
+    $("outages").replaceChildren();
+  }
+}
+
+function svg(tag, attrs, ...children) {
+  const node = document.createElementNS("http://www.w3.org/2000/svg", tag);
+  Object.entries(attrs || {}).forEach(([k, v]) => node.setAttribute(k, v));
+  children.flat().forEach((child) => node.append(child));
+  return node;
+}
+
+// renderChart draws the rtt as a line and the loss as bars from the bottom, the full
+// resolution and the downsampled points share the time axis
+function renderChart(points) {
+  const chart = $("chart");
+  if (!points.length) {
+    chart.replaceChildren(el("p", { class: "hint" }, "no history for this target in the range"));
+    return;
+  }
+  const width = 1000;
+  const height = 240;
+  const pad = { left: 50, right: 50, top: 10, bottom: 24 };
+  const start = new Date(points[0].timestamp).getTime();
+  const last = points[points.length - 1];
+  const end = Math.max(new Date(last.timestamp).getTime() + (last.resolution || 0) / 1e6, start + 1);
+  const maxRtt = Math.max(...points.map((p) => ms(p.avgRtt)), 1);
+  const x = (t) => pad.left + ((t - start) / (end - start)) * (width - pad.left - pad.right);
+  const yRtt = (v) => height - pad.bottom - (v / maxRtt) * (height - pad.top - pad.bottom);
+  const yLoss = (ratio) => height - pad.bottom - ratio * (height - pad.top - pad.bottom);
+
+  const bars = [];
+  let line = "";
+  points.forEach((p, i) => {
+    const t = new Date(p.timestamp).getTime();
+    const next = i + 1 < points.length ? new Date(points[i + 1].timestamp).getTime() : end;
+    const loss = p.failures === p.samples ? 1 : p.sent ? p.lost / p.sent : p.failures / p.samples;
+    if (loss > 0) {
+      const barX = x(t);
+      const bar = svg("rect", { class: "loss", x: barX, y: yLoss(loss), width: Math.max(x(next) - barX, 1), height: yLoss(0) - yLoss(loss) });
+      bar.append(svg("title", null, `${fmtTime(t)}: ${fmtPct(loss)} loss${p.error ? ", " + p.error : ""}`));
+      bars.push(bar);
+    }
+    if (p.avgRtt > 0) {
+      line += `${line ? "L" : "M"}${x(t).toFixed(1)},${yRtt(ms(p.avgRtt)).toFixed(1)}`;
+    }
+  });
+
+  const ticks = [];
+  for (let i = 0; i <= 4; i++) {
+    const t = start + ((end - start) * i) / 4;
+    ticks.push(svg("text", { x: x(t), y: height - 6, "text-anchor": "middle" }, new Date(t).toLocaleTimeString([], { hour: "2-digit", minute: "2-digit" })));
+  }
+  chart.replaceChildren(svg("svg", { viewBox: `0 0 ${width} ${height}`, preserveAspectRatio: "none" },
+    svg("line", { class: "axis", x1: pad.left, x2: width - pad.right, y1: yLoss(0), y2: yLoss(0) }),
+    svg("text", { x: pad.left - 6, y: pad.top + 10, "text-anchor": "end" }, `${maxRtt.toFixed(1)}ms`),
+    svg("text", { x: width - pad.right + 6, y: pad.top + 10 }, "100% loss"),
+    bars,
+    svg("path", { class: "rtt", d: line }),
+    ticks,
+  ));
+}
+
+function renderOutages(outages) {
+  const table = $("outages");
+  if (!outages.length) {
+    table.replaceChildren(el("tbody", null, el("tr", null, el("td", null, "no loss in the range"))));
+    return;
+  }
+  table.replaceChildren(
+    el("thead", null, el("tr", null, ["Start", "End", "Duration", "Lost", "Error"].map((h) => el("th", null, h)))),
+    el("tbody", null, outages.slice().reverse().map((o) => el("tr", null,
+      el("td", null, fmtTime(o.start)),
+      el("td", { class: o.ongoing ? "ongoing" : null }, o.ongoing ? "ongoing" : fmtTime(o.end)),
+      el("td", null, fmtDuration(o.duration)),
+      el("td", null, o.sent ? `${o.lost}/${o.sent}` : `${o.failures}/${o.samples} probes failed`),
+      el("td", null, o.error || ""),
+    ))),
+  );
+}
+
+$("tabs").addEventListener("click", (event) => {
+  const tab = event.target.dataset.tab;
+  if (!tab) {
+    return;
+  }
+  state.tab = tab;
+  document.querySelectorAll("#tabs button").forEach((b) => b.classList.toggle("active", b.dataset.tab === tab));
+  renderHeatmap();
+});
+$("metric").addEventListener("change", (event) => {
+  state.metric = event.target.value;
+  render();
+});
+$("refresh").addEventListener("change", schedule);
+$("reload").addEventListener("click", refresh);
+$("range").addEventListener("change", loadDetails);
+$("close-details").addEventListener("click", () => {
+  state.selected = null;
+  $("details").hidden = true;
+  renderHeatmap();
+});
+
+refresh();
+schedule();
@@ -0,0 +1,74 @@
+package pinger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/wenwenxiong/network-pinger/pkg/echo"
+)
+
+const maxDSCP = 63
+
+func validateDSCPClasses(classes []int) error {
+	for _, dscp := range classes {
+		if dscp < 0 || dscp > maxDSCP {
+			return fmt.Errorf("invalid dscp class %d, it must be between 0 and %d", dscp, maxDSCP)
+		}
+	}
+	return nil
+}
+
+// probeDSCP probes the target once per dscp class next to its unmarked probe, so queues that
+// treat voice or low latency classes differently show up in the metrics. The classes are probed
+// in parallel up to --dscp-concurrency so they add about one probe to the cycle. The ecn bits of
+// the profile tos are kept.
+func probeDSCP(ctx context.Context, config *Configuration, r ProbeResult, profile ProbeProfile, classes []int) {
+	sem := make(chan struct{}, config.DSCPConcurrency)
+	var wg sync.WaitGroup
+	for _, dscp := range classes {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(dscp int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			probeDSCPClass(ctx, config, r, profile, dscp)
+		}(dscp)
+	}
+	wg.Wait()
+}
+
+func probeDSCPClass(ctx context.Context, config *Configuration, r ProbeResult, profile ProbeProfile, dscp int) {
+	target := targetDisplayName(r)
+	stats, err := echo.Run(ctx, r.Address, echo.Options{
+		Count:    profile.Count,
+		Interval: profile.Interval.Duration,
+		Timeout:  profile.Timeout.Duration,
+		Size:     profile.Size,
+		TTL:      profile.TTL,
+		TOS:      dscp<<2 | profile.TOS&0x3,
+	})
+	if err != nil {
+		klog.Errorf("failed to probe %s %s with dscp %d: %v", r.Check, target, dscp, err)
+		return
+	}
+
+	lost := stats.Sent - stats.Recv
+	latency := float64(stats.AvgRTT) / float64(time.Millisecond)
+	klog.Infof("ping %s %s %s with dscp %d, count: %d, loss count %d, average rtt %.2fms",
+		r.Check, target, r.Address, dscp, stats.Sent, lost, latency)
+	SetDSCPMetrics(config.NodeName, r.Check, target, r.Address, dscp, latency, lost, stats.Sent)
+	if stats.Marked == 0 {
+		return
+	}
+	if stats.Remarked != 0 {
+		klog.Warningf("%d of %d echo replies from %s %s to dscp %d came back with another dscp, last one %d",
+			stats.Remarked, stats.Marked, r.Check, target, dscp, stats.ReplyTOS>>2)
+	}
+	SetDSCPReplyMetrics(config.NodeName, r.Check, target, r.Address, dscp, stats.ReplyTOS>>2, stats.Remarked != 0)
+}
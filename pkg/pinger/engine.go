@@ -0,0 +1,101 @@
+package pinger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"github.com/wenwenxiong/network-pinger/pkg/util"
+	networkClientset "pkg/client/clientset/versioned"
+)
+
+// EngineOptions inject what the pinger command builds from its flags and environment.
+type EngineOptions struct {
+	// KubeClient and NetworkClient are created from KubeConfigFile or the in cluster config when nil.
+	KubeClient    kubernetes.Interface
+	NetworkClient networkClientset.Interface
+	// Registerer receives the pinger metrics, the default registerer when nil. When it is a
+	// Gatherer as well, job mode pushes the metrics gathered from it.
+	Registerer prometheus.Registerer
+	// Sinks receive every reachability result after the built-in sinks.
+	Sinks []ResultSink
+}
+
+// engineCreated is set by the first engine, the metrics of the pinger are package globals, so a
+// second engine would write the same series.
+var engineCreated int32
+
+// Engine runs the checks of the pinger inside another program, an operator for example,
+// without the flags, the signal handling and the metrics server of the pinger command.
+// A process runs one engine at most.
+type Engine struct {
+	config *Configuration
+}
+
+// DefaultConfiguration returns the configuration of the pinger command run without flags.
+// NodeName, PodName and PodIP are read from the environment like the daemonset sets them.
+func DefaultConfiguration() *Configuration {
+	args := registerFlags(pflag.NewFlagSet("pinger", pflag.ContinueOnError))
+	config, err := args.configuration()
+	if err != nil {
+		// the defaults of the flags are valid
+		panic(err)
+	}
+	return config
+}
+
+// NewEngine validates the configuration, registers the metrics and creates the clients the
+// options do not inject. The configuration must not be changed once the engine is created.
+// It fails once an engine was created in the process.
+func NewEngine(config *Configuration, opts EngineOptions) (engine *Engine, err error) {
+	if config == nil {
+		return nil, fmt.Errorf("engine configuration is nil")
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	if !atomic.CompareAndSwapInt32(&engineCreated, 0, 1) {
+		return nil, fmt.Errorf("a pinger engine was already created, the pinger metrics allow one engine per process")
+	}
+	defer func() {
+		if err != nil {
+			atomic.StoreInt32(&engineCreated, 0)
+		}
+	}()
+	if len(config.PodProtocols) == 0 {
+		if config.PodIP == "" {
+			return nil, fmt.Errorf("pod protocols or pod ip of the engine configuration must be set")
+		}
+		for _, ip := range strings.Split(config.PodIP, ",") {
+			config.PodProtocols = append(config.PodProtocols, util.CheckProtocol(ip))
+		}
+	}
+	if opts.Registerer == nil {
+		opts.Registerer = prometheus.DefaultRegisterer
+	}
+	if err := RegisterPingerMetrics(opts.Registerer); err != nil {
+		klog.Errorf("failed to register pinger metrics: %v", err)
+		return nil, err
+	}
+	if err := config.init(opts); err != nil {
+		return nil, err
+	}
+	return &Engine{config: config}, nil
+}
+
+// Run probes until the context is canceled in server mode, or once in job mode, and returns
+// the exit code the pinger command would exit with.
+func (e *Engine) Run(ctx context.Context) int {
+	return StartPinger(ctx, e.config)
+}
+
+// Results returns the latest result of every target probed recently.
+func (e *Engine) Results() []TargetState {
+	return e.config.results.snapshot(e.config.resultTTL())
+}
@@ -0,0 +1,135 @@
+package pinger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+
+	networkv1 "pkg/apis/network/v1"
+)
+
+const (
+	ReasonUnreachable = "Unreachable"
+	ReasonReachable   = "Reachable"
+)
+
+// initEventRecorder sends events through a correlator, which aggregates repeated events and
+// rate limits every source/object pair with a token bucket.
+func (config *Configuration) initEventRecorder() {
+	broadcaster := record.NewBroadcasterWithCorrelatorOptions(record.CorrelatorOptions{
+		QPS:       config.EventQPS,
+		BurstSize: config.EventBurst,
+	})
+	broadcaster.StartStructuredLogging(3)
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: config.KubeClient.CoreV1().Events("")})
+	config.eventRecorder = broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "network-pinger", Host: config.NodeName})
+}
+
+// eventSink emits an event when the reachability of a target changes,
+// repeated results of the same state never produce a new event.
+type eventSink struct {
+	config *Configuration
+
+	mu     sync.Mutex
+	last   map[string]eventState
+	pruned time.Time
+}
+
+type eventState struct {
+	healthy bool
+	seen    time.Time
+}
+
+func newEventSink(config *Configuration) *eventSink {
+	return &eventSink{config: config, last: make(map[string]eventState), pruned: time.Now()}
+}
+
+func (s *eventSink) Record(r ProbeResult) {
+	s.mu.Lock()
+	previous, ok := s.last[r.Key()]
+	s.last[r.Key()] = eventState{healthy: r.Healthy, seen: r.Timestamp}
+	// targets not probed for a while are gone, like in the result store
+	if ttl := s.config.resultTTL(); time.Since(s.pruned) > ttl {
+		for key, state := range s.last {
+			if time.Since(state.seen) > ttl {
+				delete(s.last, key)
+			}
+		}
+		s.pruned = time.Now()
+	}
+	s.mu.Unlock()
+
+	if (!ok && r.Healthy) || (ok && previous.healthy == r.Healthy) {
+		return
+	}
+	s.config.recordTransitionEvent(r)
+}
+
+func (config *Configuration) recordTransitionEvent(current ProbeResult) {
+	ref, err := config.eventReference(current)
+	if err != nil {
+		klog.Warningf("failed to get event object of %s: %v", targetDisplayName(current), err)
+		return
+	}
+	since := current.Timestamp.Format(time.RFC3339)
+	if current.Healthy {
+		config.eventRecorder.Eventf(ref, v1.EventTypeNormal, ReasonReachable,
+			"%s %s reachable from %s again since %s", current.Check, targetDisplayName(current), config.NodeName, since)
+		return
+	}
+	message := fmt.Sprintf("%s %s unreachable from %s since %s", current.Check, targetDisplayName(current), config.NodeName, since)
+	if current.Error != "" {
+		message += ": " + current.Error
+	} else if current.Sent != 0 {
+		message += fmt.Sprintf(": lost %d of %d", current.Lost, current.Sent)
+	}
+	config.eventRecorder.Event(ref, v1.EventTypeWarning, ReasonUnreachable, message)
+}
+
+// eventReference finds the object the event belongs to: the probed pod, node or ip,
+// the PingTarget for declared targets and the pinger pod itself for apiserver and dns.
+// The objects come from the informer caches, so a transition never waits for the apiserver.
+func (config *Configuration) eventReference(r ProbeResult) (*v1.ObjectReference, error) {
+	switch r.Check {
+	case CheckPod:
+		pod, err := config.podLister.Pods(r.Namespace).Get(r.Name)
+		if err != nil {
+			return nil, err
+		}
+		return &v1.ObjectReference{Kind: "Pod", APIVersion: "v1", Namespace: pod.Namespace, Name: pod.Name, UID: pod.UID}, nil
+	case CheckNode:
+		node, err := config.nodeLister.Get(r.Name)
+		if err != nil {
+			return nil, err
+		}
+		return &v1.ObjectReference{Kind: "Node", APIVersion: "v1", Name: node.Name, UID: node.UID}, nil
+	case CheckIP:
+		ip, err := config.IPLister.Get(r.Name)
+		if err != nil {
+			return nil, err
+		}
+		return &v1.ObjectReference{Kind: "IP", APIVersion: networkv1.SchemeGroupVersion.String(), Name: ip.Name, UID: ip.UID}, nil
+	case CheckTarget:
+		target, err := config.pingTargetLister.PingTargets(r.Namespace).Get(r.Name)
+		if err != nil {
+			return nil, err
+		}
+		return &v1.ObjectReference{Kind: "PingTarget", APIVersion: networkv1.SchemeGroupVersion.String(), Namespace: target.Namespace, Name: target.Name, UID: target.UID}, nil
+	default:
+		return config.selfReference()
+	}
+}
+
+func (config *Configuration) selfReference() (*v1.ObjectReference, error) {
+	pod, err := config.podLister.Pods(config.DaemonSetNamespace).Get(config.PodName)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.ObjectReference{Kind: "Pod", APIVersion: "v1", Namespace: pod.Namespace, Name: pod.Name, UID: pod.UID}, nil
+}
@@ -0,0 +1,232 @@
+package pinger
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/wenwenxiong/network-pinger/pkg/influx"
+	"github.com/wenwenxiong/network-pinger/pkg/statsd"
+)
+
+const (
+	ExporterInflux = "influxdb"
+	ExporterStatsD = "statsd"
+
+	influxMeasurement = "pinger_probe"
+)
+
+// lineBuffer batches the lines of an exporter and keeps them while its collector is
+// unreachable, the oldest lines are dropped once the buffer is full. Over udp a collector is
+// only seen unreachable when the kernel refuses to send, so the buffer mostly helps http.
+type lineBuffer struct {
+	name      string
+	write     func(ctx context.Context, lines [][]byte) (int, error)
+	batchSize int
+	size      int
+	interval  time.Duration
+	timeout   time.Duration
+
+	mu    sync.Mutex
+	lines [][]byte
+	full  chan struct{}
+	// flushMu keeps the batches in order when the shutdown flushes while a flush runs
+	flushMu sync.Mutex
+}
+
+func newLineBuffer(config *Configuration, name string, write func(ctx context.Context, lines [][]byte) (int, error)) *lineBuffer {
+	return &lineBuffer{
+		name:      name,
+		write:     write,
+		batchSize: config.ExportBatchSize,
+		size:      config.ExportBufferSize,
+		interval:  config.ExportFlushInterval,
+		timeout:   config.ExportTimeout,
+		full:      make(chan struct{}, 1),
+	}
+}
+
+func (b *lineBuffer) add(lines ...[]byte) {
+	b.mu.Lock()
+	b.lines = append(b.lines, lines...)
+	dropped := b.trim()
+	buffered := len(b.lines)
+	b.mu.Unlock()
+	if dropped != 0 {
+		AddExporterDroppedMetrics(b.name, dropped)
+	}
+	if buffered >= b.batchSize {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// trim drops the oldest lines over the size of the buffer, mu must be held.
+func (b *lineBuffer) trim() int {
+	over := len(b.lines) - b.size
+	if over <= 0 {
+		return 0
+	}
+	b.lines = append([][]byte(nil), b.lines[over:]...)
+	return over
+}
+
+// run flushes every interval and as soon as a batch is full until stopCh is closed,
+// the shutdown flushes what is left.
+func (b *lineBuffer) run(stopCh <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+			case <-b.full:
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+			_ = b.flush(ctx)
+			cancel()
+		}
+	}()
+}
+
+// flush writes the buffered lines batch by batch, the lines of a batch that were not sent go
+// back to the front of the buffer for the next flush. Statsd counters would count twice if the
+// sent ones were retried.
+func (b *lineBuffer) flush(ctx context.Context) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+	for {
+		b.mu.Lock()
+		n := min(len(b.lines), b.batchSize)
+		if n == 0 {
+			b.mu.Unlock()
+			SetExporterBufferedMetrics(b.name, 0)
+			return nil
+		}
+		batch := b.lines[:n:n]
+		b.lines = b.lines[n:]
+		b.mu.Unlock()
+
+		if sent, err := b.write(ctx, batch); err != nil {
+			n -= sent
+			b.mu.Lock()
+			b.lines = append(batch[sent:], b.lines...)
+			dropped := b.trim()
+			buffered := len(b.lines)
+			b.mu.Unlock()
+			if dropped != 0 {
+				AddExporterDroppedMetrics(b.name, dropped)
+			}
+			SetExporterBufferedMetrics(b.name, buffered)
+			klog.Errorf("failed to send %d lines to %s, %d lines buffered: %v", n, b.name, buffered, err)
+			return err
+		}
+	}
+}
+
+// initExporters creates the influxdb and statsd sinks, which push every result as it comes.
+func (config *Configuration) initExporters() ([]ResultSink, error) {
+	var sinks []ResultSink
+	if config.InfluxURL != "" {
+		client, err := influx.NewClient(config.InfluxURL, config.InfluxToken, config.ExportTimeout)
+		if err != nil {
+			klog.Errorf("failed to create influxdb client: %v", err)
+			return nil, err
+		}
+		sink := &influxSink{config: config}
+		sink.buffer = newLineBuffer(config, ExporterInflux, func(ctx context.Context, lines [][]byte) (int, error) {
+			sent, err := client.Write(ctx, lines)
+			var rejected *influx.RejectedError
+			if errors.As(err, &rejected) {
+				// retrying lines the server refuses would block the buffer forever
+				klog.Errorf("drop %d lines: %v", len(lines), err)
+				AddExporterDroppedMetrics(ExporterInflux, len(lines))
+				return len(lines), nil
+			}
+			return sent, err
+		})
+		config.exporters = append(config.exporters, sink.buffer)
+		sinks = append(sinks, sink)
+	}
+	if config.StatsDAddress != "" {
+		client, err := statsd.NewClient(config.StatsDAddress)
+		if err != nil {
+			klog.Errorf("failed to create statsd client: %v", err)
+			return nil, err
+		}
+		sink := &statsdSink{config: config}
+		sink.buffer = newLineBuffer(config, ExporterStatsD, client.Write)
+		config.exporters = append(config.exporters, sink.buffer)
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// flushExporters sends what the exporters still buffer, at the end of job mode and on shutdown.
+func (config *Configuration) flushExporters(ctx context.Context) {
+	for _, exporter := range config.exporters {
+		_ = exporter.flush(ctx)
+	}
+}
+
+// influxSink writes every result as a point of the pinger_probe measurement.
+type influxSink struct {
+	config *Configuration
+	buffer *lineBuffer
+}
+
+func (s *influxSink) Record(r ProbeResult) {
+	point := influx.Point{
+		Measurement: influxMeasurement,
+		Tags:        make(map[string]string),
+		Fields: map[string]interface{}{
+			"sent":       r.Sent,
+			"lost":       r.Lost,
+			"avg_rtt_ms": float64(r.AvgRTT) / float64(time.Millisecond),
+			"healthy":    r.Healthy,
+		},
+		Time: r.Timestamp,
+	}
+	for _, d := range s.config.resultDimensions(r) {
+		point.Tags[d.name] = d.value
+	}
+	if r.Error != "" {
+		point.Fields["error"] = r.Error
+	}
+	s.buffer.add(point.AppendLine(nil))
+}
+
+// statsdSink sends the sent and lost counters, the health gauge and the rtt timing of every result.
+type statsdSink struct {
+	config *Configuration
+	buffer *lineBuffer
+}
+
+func (s *statsdSink) Record(r ProbeResult) {
+	dimensions := s.config.resultDimensions(r)
+	tags := make([]statsd.Tag, 0, len(dimensions))
+	for _, d := range dimensions {
+		tags = append(tags, statsd.Tag{Key: d.name, Value: d.value})
+	}
+	flavor, prefix := s.config.StatsDFlavor, s.config.StatsDPrefix+".probe."
+	healthy := 0.0
+	if r.Healthy {
+		healthy = 1
+	}
+	lines := [][]byte{
+		statsd.AppendMetric(nil, flavor, prefix+"sent", float64(r.Sent), statsd.TypeCounter, tags),
+		statsd.AppendMetric(nil, flavor, prefix+"lost", float64(r.Lost), statsd.TypeCounter, tags),
+		statsd.AppendMetric(nil, flavor, prefix+"healthy", healthy, statsd.TypeGauge, tags),
+	}
+	if r.AvgRTT > 0 {
+		lines = append(lines, statsd.AppendMetric(nil, flavor, prefix+"rtt_ms", float64(r.AvgRTT)/float64(time.Millisecond), statsd.TypeTiming, tags))
+	}
+	s.buffer.add(lines...)
+}
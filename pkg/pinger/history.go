@@ -0,0 +1,508 @@
+package pinger
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// HistoryPoint is a result kept at full resolution, or once older than the history retention
+// the aggregate of the results of a target within Resolution, which starts at Timestamp.
+// AvgRTT is the mean of the average rtt of the results that had one.
+type HistoryPoint struct {
+	Timestamp  time.Time     `json:"timestamp"`
+	Resolution time.Duration `json:"resolution,omitempty"`
+	Samples    int           `json:"samples"`
+	Failures   int           `json:"failures"`
+	Sent       int           `json:"sent"`
+	Lost       int           `json:"lost"`
+	AvgRTT     time.Duration `json:"avgRtt"`
+	MaxRTT     time.Duration `json:"maxRtt"`
+	Error      string        `json:"error,omitempty"`
+
+	rttSamples int
+}
+
+// lossy is true when a result of the point failed or lost packets.
+func (p HistoryPoint) lossy() bool {
+	return p.Failures != 0 || p.Lost != 0
+}
+
+func newHistoryPoint(r ProbeResult) HistoryPoint {
+	p := HistoryPoint{
+		Timestamp: r.Timestamp,
+		Samples:   1,
+		Sent:      r.Sent,
+		Lost:      r.Lost,
+		AvgRTT:    r.AvgRTT,
+		MaxRTT:    r.AvgRTT,
+		Error:     r.Error,
+	}
+	if !r.Healthy {
+		p.Failures = 1
+	}
+	if r.AvgRTT > 0 {
+		p.rttSamples = 1
+	}
+	return p
+}
+
+// merge adds the results of other to the aggregate.
+func (p *HistoryPoint) merge(other HistoryPoint) {
+	if rttSamples := p.rttSamples + other.rttSamples; rttSamples != 0 {
+		p.AvgRTT = (p.AvgRTT*time.Duration(p.rttSamples) + other.AvgRTT*time.Duration(other.rttSamples)) / time.Duration(rttSamples)
+		p.rttSamples = rttSamples
+	}
+	if other.MaxRTT > p.MaxRTT {
+		p.MaxRTT = other.MaxRTT
+	}
+	p.Samples += other.Samples
+	p.Failures += other.Failures
+	p.Sent += other.Sent
+	p.Lost += other.Lost
+	if other.Error != "" {
+		p.Error = other.Error
+	}
+}
+
+// HistoryTarget identifies the target a history or an outage timeline belongs to.
+type HistoryTarget struct {
+	Key       string `json:"key"`
+	Target    string `json:"target"`
+	Check     string `json:"check"`
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	NodeName  string `json:"nodeName,omitempty"`
+	Address   string `json:"address,omitempty"`
+	MeshType  string `json:"meshType,omitempty"`
+	ProbeType string `json:"probeType,omitempty"`
+}
+
+func newHistoryTarget(r ProbeResult) HistoryTarget {
+	return HistoryTarget{
+		Key:       r.Key(),
+		Target:    targetDisplayName(r),
+		Check:     r.Check,
+		Name:      r.Name,
+		Namespace: r.Namespace,
+		NodeName:  r.NodeName,
+		Address:   r.Address,
+		MeshType:  r.MeshType,
+		ProbeType: r.ProbeType,
+	}
+}
+
+// matches is true when query is the key, the display name, the namespaced name, the name
+// or the address of the target.
+func (t HistoryTarget) matches(query string) bool {
+	switch query {
+	case t.Key, t.Target, t.Name, t.Address:
+		return true
+	}
+	return t.Namespace != "" && query == t.Namespace+"/"+t.Name
+}
+
+// TargetHistory is the history of a target, oldest point first.
+type TargetHistory struct {
+	HistoryTarget
+	Points []HistoryPoint `json:"points"`
+}
+
+// Outage is a period of consecutive points that failed or lost packets. End is the time of
+// the first point after the period, or of its last point while it is ongoing.
+type Outage struct {
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration"`
+	Ongoing  bool          `json:"ongoing"`
+	Samples  int           `json:"samples"`
+	Failures int           `json:"failures"`
+	Sent     int           `json:"sent"`
+	Lost     int           `json:"lost"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// TargetOutages is the outage timeline of a target, oldest outage first.
+type TargetOutages struct {
+	HistoryTarget
+	Outages []Outage `json:"outages"`
+}
+
+// outages returns the loss periods of the points.
+func outages(points []HistoryPoint) []Outage {
+	var result []Outage
+	var current *Outage
+	for _, p := range points {
+		if !p.lossy() {
+			if current != nil {
+				current.End = p.Timestamp
+				current.Duration = current.End.Sub(current.Start)
+				result = append(result, *current)
+				current = nil
+			}
+			continue
+		}
+		if current == nil {
+			current = &Outage{Start: p.Timestamp}
+		}
+		current.End = p.Timestamp.Add(p.Resolution)
+		current.Samples += p.Samples
+		current.Failures += p.Failures
+		current.Sent += p.Sent
+		current.Lost += p.Lost
+		if p.Error != "" {
+			current.Error = p.Error
+		}
+	}
+	if current != nil {
+		current.Ongoing = true
+		current.Duration = current.End.Sub(current.Start)
+		result = append(result, *current)
+	}
+	return result
+}
+
+// historyRing keeps the newest points up to its size, the slice grows as points come and
+// shrinks as they expire so targets probed less often do not hold unused memory.
+type historyRing struct {
+	points []HistoryPoint
+	start  int
+	count  int
+	size   int
+}
+
+func (r *historyRing) len() int {
+	return r.count
+}
+
+func (r *historyRing) at(i int) *HistoryPoint {
+	return &r.points[(r.start+i)%len(r.points)]
+}
+
+func (r *historyRing) newest() *HistoryPoint {
+	if r.count == 0 {
+		return nil
+	}
+	return r.at(r.count - 1)
+}
+
+// push appends the point and returns the oldest one when it had to make room for it.
+func (r *historyRing) push(p HistoryPoint) (HistoryPoint, bool) {
+	switch {
+	case r.count < len(r.points):
+		r.points[(r.start+r.count)%len(r.points)] = p
+	case r.count < r.size:
+		r.points, r.start = append(r.slice(), p), 0
+	default:
+		evicted := r.points[r.start]
+		r.points[r.start] = p
+		r.start = (r.start + 1) % len(r.points)
+		return evicted, true
+	}
+	r.count++
+	return HistoryPoint{}, false
+}
+
+func (r *historyRing) popOldest() HistoryPoint {
+	p := r.points[r.start]
+	r.start = (r.start + 1) % len(r.points)
+	r.count--
+	if r.count < len(r.points)/4 {
+		r.points, r.start = r.slice(), 0
+	}
+	return p
+}
+
+// slice returns a copy of the points, oldest first.
+func (r *historyRing) slice() []HistoryPoint {
+	points := make([]HistoryPoint, r.count)
+	for i := range points {
+		points[i] = *r.at(i)
+	}
+	return points
+}
+
+type targetHistory struct {
+	target      HistoryTarget
+	raw         historyRing
+	downsampled historyRing
+}
+
+// historyStore keeps the results of every target, at full resolution for the history retention
+// and downsampled to the history resolution until the downsampled retention. The points of all
+// targets share the total sample budget evenly, at most half of the share of a target goes to
+// its downsampled points.
+type historyStore struct {
+	retention            time.Duration
+	resolution           time.Duration
+	downsampledRetention time.Duration
+	maxSamples           int
+	maxTotalSamples      int
+	file                 string
+
+	mu      sync.RWMutex
+	targets map[string]*targetHistory
+}
+
+func newHistoryStore(config *Configuration) *historyStore {
+	return &historyStore{
+		retention:            config.HistoryRetention,
+		resolution:           config.HistoryResolution,
+		downsampledRetention: config.HistoryDownsampledRetention,
+		maxSamples:           config.HistoryMaxSamples,
+		maxTotalSamples:      config.HistoryMaxTotalSamples,
+		file:                 config.HistoryFile,
+		targets:              make(map[string]*targetHistory),
+	}
+}
+
+func (s *historyStore) newTargetHistory(target HistoryTarget) *targetHistory {
+	h := &targetHistory{target: target}
+	h.raw.size = s.maxSamples
+	if s.downsampledRetention > s.retention {
+		// points evicted by the max samples are downsampled before the retention ends
+		h.downsampled.size = int(s.downsampledRetention/s.resolution) + 1
+	}
+	return h
+}
+
+func (s *historyStore) Record(r ProbeResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := r.Key()
+	h, ok := s.targets[key]
+	if !ok {
+		h = s.newTargetHistory(newHistoryTarget(r))
+		s.targets[key] = h
+	}
+	// the node of a pod and the topology of a target may change
+	h.target = newHistoryTarget(r)
+	if evicted, ok := h.raw.push(newHistoryPoint(r)); ok {
+		s.downsample(h, evicted)
+	}
+	s.expireTarget(h, r.Timestamp)
+}
+
+// downsample adds a point leaving the full resolution to the aggregate of its period.
+func (s *historyStore) downsample(h *targetHistory, p HistoryPoint) {
+	if h.downsampled.size == 0 {
+		return
+	}
+	bucket := p.Timestamp.Truncate(s.resolution)
+	if last := h.downsampled.newest(); last != nil && last.Timestamp.Equal(bucket) {
+		last.merge(p)
+		return
+	}
+	aggregate := HistoryPoint{Timestamp: bucket, Resolution: s.resolution}
+	aggregate.merge(p)
+	h.downsampled.push(aggregate)
+}
+
+// share is the number of points of a target within the total sample budget.
+func (s *historyStore) share() int {
+	share := s.maxTotalSamples
+	if len(s.targets) != 0 {
+		share /= len(s.targets)
+	}
+	if share < 2 {
+		share = 2
+	}
+	return share
+}
+
+// expireTarget moves the points older than the retention or beyond the share of the target to
+// the downsampled history and drops the downsampled points older than the downsampled retention
+// or beyond half of the share.
+func (s *historyStore) expireTarget(h *targetHistory, now time.Time) {
+	share := s.share()
+	for h.downsampled.len() > share/2 {
+		h.downsampled.popOldest()
+	}
+	rawLimit := share - h.downsampled.len()
+	if rawLimit > s.maxSamples {
+		rawLimit = s.maxSamples
+	}
+	for h.raw.len() != 0 && (h.raw.len() > rawLimit || now.Sub(h.raw.at(0).Timestamp) > s.retention) {
+		s.downsample(h, h.raw.popOldest())
+	}
+	for h.downsampled.len() != 0 && (h.downsampled.len() > share/2 || now.Sub(h.downsampled.at(0).Timestamp.Add(s.resolution)) > s.downsampledRetention) {
+		h.downsampled.popOldest()
+	}
+}
+
+// expire ages the history of every target and forgets the targets with no point left.
+func (s *historyStore) expire(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, h := range s.targets {
+		s.expireTarget(h, now)
+		if h.raw.len() == 0 && h.downsampled.len() == 0 {
+			delete(s.targets, key)
+		}
+	}
+}
+
+// history returns the points since the given time of the targets matching the query and
+// the check, every target when query is empty.
+func (s *historyStore) history(query, check string, since time.Time) []TargetHistory {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var result []TargetHistory
+	for _, h := range s.targets {
+		if (query != "" && !h.target.matches(query)) || (check != "" && h.target.Check != check) {
+			continue
+		}
+		points := append(h.downsampled.slice(), h.raw.slice()...)
+		first := sort.Search(len(points), func(i int) bool {
+			return !points[i].Timestamp.Add(points[i].Resolution).Before(since)
+		})
+		result = append(result, TargetHistory{HistoryTarget: h.target, Points: points[first:]})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// outages returns the outage timeline of the targets matching the query and the check, the
+// targets without outage since the given time are left out.
+func (s *historyStore) outages(query, check string, since time.Time) []TargetOutages {
+	var result []TargetOutages
+	for _, h := range s.history(query, check, since) {
+		if periods := outages(h.Points); len(periods) != 0 {
+			result = append(result, TargetOutages{HistoryTarget: h.HistoryTarget, Outages: periods})
+		}
+	}
+	return result
+}
+
+// historySnapshot is the content of the history file.
+type historySnapshot struct {
+	Targets []historySnapshotTarget `json:"targets"`
+}
+
+type historySnapshotTarget struct {
+	HistoryTarget
+	Raw         []HistoryPoint `json:"raw"`
+	Downsampled []HistoryPoint `json:"downsampled"`
+}
+
+// save writes the history to the history file, through a temporary file so a pinger killed
+// while writing keeps the previous history.
+func (s *historyStore) save() error {
+	if s.file == "" {
+		return nil
+	}
+	s.mu.RLock()
+	snapshot := historySnapshot{Targets: make([]historySnapshotTarget, 0, len(s.targets))}
+	for _, h := range s.targets {
+		snapshot.Targets = append(snapshot.Targets, historySnapshotTarget{
+			HistoryTarget: h.target,
+			Raw:           h.raw.slice(),
+			Downsampled:   h.downsampled.slice(),
+		})
+	}
+	s.mu.RUnlock()
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		klog.Errorf("failed to encode history: %v", err)
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.file), filepath.Base(s.file)+".*")
+	if err != nil {
+		klog.Errorf("failed to save history to %s: %v", s.file, err)
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Close()
+	} else {
+		_ = tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.file)
+	}
+	if err != nil {
+		klog.Errorf("failed to save history to %s: %v", s.file, err)
+		return err
+	}
+	klog.V(3).Infof("saved the history of %d targets to %s", len(snapshot.Targets), s.file)
+	return nil
+}
+
+// load restores the history saved by a previous pinger, a missing file is an empty history
+// and the points beyond the current retention settings are aged out.
+func (s *historyStore) load() error {
+	if s.file == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		klog.Errorf("failed to read history file %s: %v", s.file, err)
+		return err
+	}
+	var snapshot historySnapshot
+	if err = json.Unmarshal(data, &snapshot); err != nil {
+		klog.Errorf("failed to decode history file %s: %v", s.file, err)
+		return err
+	}
+
+	s.mu.Lock()
+	for _, t := range snapshot.Targets {
+		h := s.newTargetHistory(t.HistoryTarget)
+		for _, p := range t.Downsampled {
+			p.Resolution = 0
+			p.rttSamples = p.Samples - p.Failures
+			s.downsample(h, p)
+		}
+		for _, p := range t.Raw {
+			if p.AvgRTT > 0 {
+				p.rttSamples = 1
+			}
+			if evicted, ok := h.raw.push(p); ok {
+				s.downsample(h, evicted)
+			}
+		}
+		s.targets[t.Key] = h
+	}
+	s.mu.Unlock()
+	s.expire(time.Now())
+	klog.Infof("loaded the history of %d targets from %s", len(snapshot.Targets), s.file)
+	return nil
+}
+
+// run ages the history every resolution and saves it to the history file every save interval
+// until stopCh is closed, the shutdown saves it a last time.
+func (s *historyStore) run(stopCh <-chan struct{}, saveInterval time.Duration) {
+	go wait.Until(func() {
+		s.expire(time.Now())
+	}, s.resolution, stopCh)
+	if s.file != "" {
+		go wait.Until(func() {
+			_ = s.save()
+		}, saveInterval, stopCh)
+	}
+}
+
+// parseHistorySince accepts a duration back from now or a RFC 3339 time, empty is the
+// whole history.
+func parseHistorySince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(value))
+}
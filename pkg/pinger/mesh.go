@@ -0,0 +1,163 @@
+package pinger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/wenwenxiong/network-pinger/pkg/util"
+)
+
+// The pingers of a DaemonSet in the pod network probe each other pod-to-pod as MeshTypePod, the
+// pingers of a DaemonSet in the host network probe the host addresses of each other host-to-host
+// as MeshTypeNode. Both DaemonSets together export both matrices.
+const (
+	MeshTypePod  = "pod"
+	MeshTypeNode = "node"
+)
+
+// meshPeer is a sibling pinger pod of the DaemonSet running on another node.
+type meshPeer struct {
+	PodName  string
+	NodeName string
+	HostIP   string
+	PodIPs   []string
+}
+
+// meshPeers finds the other pods of the pinger DaemonSet through its own selector.
+func meshPeers(config *Configuration) ([]meshPeer, error) {
+	ds, err := config.daemonSetLister.DaemonSets(config.DaemonSetNamespace).Get(config.DaemonSetName)
+	if err != nil {
+		klog.Errorf("failed to get daemonset %s/%s: %v", config.DaemonSetNamespace, config.DaemonSetName, err)
+		return nil, err
+	}
+	selector, err := metav1.LabelSelectorAsSelector(ds.Spec.Selector)
+	if err != nil {
+		klog.Errorf("invalid selector of daemonset %s/%s: %v", config.DaemonSetNamespace, config.DaemonSetName, err)
+		return nil, err
+	}
+	pods, err := config.podLister.Pods(config.DaemonSetNamespace).List(selector)
+	if err != nil {
+		klog.Errorf("failed to list pods of daemonset %s/%s: %v", config.DaemonSetNamespace, config.DaemonSetName, err)
+		return nil, err
+	}
+
+	peers := make([]meshPeer, 0, len(pods))
+	for _, pod := range pods {
+		if pod.Name == config.PodName || pod.Status.Phase != v1.PodRunning || pod.Spec.NodeName == "" {
+			continue
+		}
+		peer := meshPeer{PodName: pod.Name, NodeName: pod.Spec.NodeName, HostIP: pod.Status.HostIP}
+		for _, podIP := range pod.Status.PodIPs {
+			if util.ContainsString(config.PodProtocols, util.CheckProtocol(podIP.IP)) {
+				peer.PodIPs = append(peer.PodIPs, podIP.IP)
+			}
+		}
+		peers = append(peers, peer)
+	}
+	return peers, nil
+}
+
+// hostNetwork tells whether the pinger runs in the network namespace of its node, where its pod
+// ip is the host ip.
+func (config *Configuration) hostNetwork() bool {
+	if config.HostIP == "" {
+		return false
+	}
+	for _, ip := range strings.Split(config.PodIP, ",") {
+		if ip == config.HostIP {
+			return true
+		}
+	}
+	return false
+}
+
+// pingMesh probes the sibling pingers picked by the mesh strategy, pod-to-pod from the pod network
+// or host-to-host from the host network, so that all the pingers of the DaemonSet together export
+// the node to node matrix. The pod and node checks that failed are recorded in failures, a
+// failure already recorded is kept.
+func pingMesh(ctx context.Context, config *Configuration, failures map[string]error) {
+	fail := func(check string, err error) {
+		if failures[check] == nil {
+			failures[check] = err
+		}
+	}
+	peers, err := meshPeers(config)
+	if err != nil {
+		fail(CheckPod, err)
+		fail(CheckNode, err)
+		return
+	}
+	nodes := make(map[string]bool, len(peers))
+	for _, peer := range peers {
+		nodes[peer.NodeName] = true
+	}
+
+	var zones map[string]string
+	if config.MeshStrategy == MeshStrategyTopology {
+		if zones, err = nodeZones(config); err != nil {
+			fail(CheckPod, err)
+			fail(CheckNode, err)
+			return
+		}
+	}
+	selected := config.meshSelector.selectPeers(config.NodeName, zones[config.NodeName], peers, zones)
+	klog.Infof("start to check mesh connectivity with %d of %d peers, strategy %s", len(selected), len(peers), config.MeshStrategy)
+	SetMeshCoverageMetrics(config.NodeName, len(peers), len(selected),
+		config.meshSelector.coverage(len(peers)), config.meshSelector.fullCoverageCycles(len(peers)))
+
+	hostNetwork := config.hostNetwork()
+	for _, peer := range selected {
+		if hostNetwork {
+			if peer.HostIP != "" && util.ContainsString(config.PodProtocols, util.CheckProtocol(peer.HostIP)) {
+				if err := meshProbe(ctx, config, peer, MeshTypeNode, peer.HostIP); err != nil {
+					fail(CheckNode, err)
+				}
+			}
+			continue
+		}
+		for _, podIP := range peer.PodIPs {
+			if err := meshProbe(ctx, config, peer, MeshTypePod, podIP); err != nil {
+				fail(CheckPod, err)
+			}
+		}
+	}
+
+	for node := range config.meshNodes {
+		if !nodes[node] {
+			klog.Infof("pinger of node %s is gone, drop its mesh metrics", node)
+			DeleteMeshMetrics(node)
+		}
+	}
+	config.meshNodes = nodes
+}
+
+func meshProbe(ctx context.Context, config *Configuration, peer meshPeer, meshType, address string) error {
+	result := ProbeResult{Check: CheckNode, Name: peer.NodeName, NodeName: peer.NodeName, Address: address, MeshType: meshType, Started: time.Now()}
+	profile := config.probeProfile(ProfileNode)
+	if meshType == MeshTypePod {
+		result = ProbeResult{Check: CheckPod, Name: peer.PodName, Namespace: config.DaemonSetNamespace, NodeName: peer.NodeName, Address: address, MeshType: meshType, Started: time.Now()}
+		profile = config.probeProfile(ProfilePod)
+	}
+	sent, recv, avgRtt, err := icmpProbe(ctx, address, profile)
+	if err != nil {
+		klog.Errorf("failed to run pinger for %s %s on node %s: %v", meshType, address, peer.NodeName, err)
+		result.Error = err.Error()
+		config.recordResult(result)
+		return err
+	}
+
+	result.Sent, result.Lost, result.AvgRTT = sent, sent-recv, avgRtt
+	result.Healthy = sent != 0 && result.Lost == 0
+	config.recordResult(result)
+	probeDSCP(ctx, config, result, profile, config.dscpClasses())
+	if !result.Healthy {
+		return fmt.Errorf("ping failed")
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package pinger
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// MeshStrategyFull probes every peer in every cycle.
+	MeshStrategyFull = "full"
+	// MeshStrategyRandom probes k peers per cycle, rotating through a shuffled order
+	// so that every peer is probed once every ceil(n/k) cycles.
+	MeshStrategyRandom = "random"
+	// MeshStrategyHash probes the k peers ranked highest by rendezvous hashing,
+	// the selection only changes for the peers that join or leave.
+	MeshStrategyHash = "hash"
+	// MeshStrategyTopology probes every peer of the own zone and one peer in each of k remote zones,
+	// rotating through the remote zones and their peers.
+	MeshStrategyTopology = "topology"
+)
+
+var meshStrategies = []string{MeshStrategyFull, MeshStrategyRandom, MeshStrategyHash, MeshStrategyTopology}
+
+// meshSelector picks the peers probed in a cycle and remembers when every peer was last probed.
+type meshSelector struct {
+	strategy       string
+	peers          int
+	remoteZones    int
+	coverageWindow time.Duration
+
+	cycle      int
+	order      []string
+	offset     int
+	lastProbed map[string]time.Time
+}
+
+func newMeshSelector(config *Configuration) (*meshSelector, error) {
+	valid := false
+	for _, strategy := range meshStrategies {
+		valid = valid || strategy == config.MeshStrategy
+	}
+	if !valid {
+		return nil, fmt.Errorf("unsupported mesh strategy %q", config.MeshStrategy)
+	}
+	if config.MeshStrategy != MeshStrategyFull && config.MeshPeers <= 0 {
+		return nil, fmt.Errorf("--mesh-peers must be positive for the %s strategy", config.MeshStrategy)
+	}
+	return &meshSelector{
+		strategy:       config.MeshStrategy,
+		peers:          config.MeshPeers,
+		remoteZones:    config.MeshRemoteZones,
+		coverageWindow: config.MeshCoverageWindow,
+		lastProbed:     make(map[string]time.Time),
+	}, nil
+}
+
+// selectPeers returns the peers to probe in this cycle, zones maps node names to their zone
+// and is only needed by the topology strategy.
+func (s *meshSelector) selectPeers(self, selfZone string, peers []meshPeer, zones map[string]string) []meshPeer {
+	s.cycle++
+	byNode := make(map[string]meshPeer, len(peers))
+	for _, peer := range peers {
+		byNode[peer.NodeName] = peer
+	}
+
+	var nodes []string
+	switch s.strategy {
+	case MeshStrategyRandom:
+		nodes = s.rotate(byNode)
+	case MeshStrategyHash:
+		nodes = s.rendezvous(self, byNode)
+	case MeshStrategyTopology:
+		nodes = s.topology(selfZone, byNode, zones)
+	default:
+		for node := range byNode {
+			nodes = append(nodes, node)
+		}
+	}
+
+	sort.Strings(nodes)
+	selected := make([]meshPeer, 0, len(nodes))
+	now := time.Now()
+	for _, node := range nodes {
+		selected = append(selected, byNode[node])
+		s.lastProbed[node] = now
+	}
+	for node := range s.lastProbed {
+		if _, ok := byNode[node]; !ok {
+			delete(s.lastProbed, node)
+		}
+	}
+	return selected
+}
+
+func (s *meshSelector) rotate(byNode map[string]meshPeer) []string {
+	changed := len(s.order) != len(byNode)
+	for _, node := range s.order {
+		if _, ok := byNode[node]; !ok {
+			changed = true
+		}
+	}
+	if changed || s.offset >= len(s.order) {
+		s.order = s.order[:0]
+		for node := range byNode {
+			s.order = append(s.order, node)
+		}
+		rand.Shuffle(len(s.order), func(i, j int) { s.order[i], s.order[j] = s.order[j], s.order[i] })
+		s.offset = 0
+	}
+	end := s.offset + s.peers
+	if end > len(s.order) {
+		end = len(s.order)
+	}
+	nodes := append([]string(nil), s.order[s.offset:end]...)
+	s.offset = end
+	return nodes
+}
+
+func (s *meshSelector) rendezvous(self string, byNode map[string]meshPeer) []string {
+	type scored struct {
+		node  string
+		score uint64
+	}
+	scores := make([]scored, 0, len(byNode))
+	for node := range byNode {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(self + "/" + node))
+		scores = append(scores, scored{node, h.Sum64()})
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i].score > scores[j].score })
+	if len(scores) > s.peers {
+		scores = scores[:s.peers]
+	}
+	nodes := make([]string, 0, len(scores))
+	for _, sc := range scores {
+		nodes = append(nodes, sc.node)
+	}
+	return nodes
+}
+
+func (s *meshSelector) topology(selfZone string, byNode map[string]meshPeer, zones map[string]string) []string {
+	var nodes []string
+	remote := make(map[string][]string)
+	for node := range byNode {
+		if zones[node] == selfZone {
+			nodes = append(nodes, node)
+		} else {
+			remote[zones[node]] = append(remote[zones[node]], node)
+		}
+	}
+
+	remoteZones := make([]string, 0, len(remote))
+	for zone := range remote {
+		remoteZones = append(remoteZones, zone)
+		sort.Strings(remote[zone])
+	}
+	sort.Strings(remoteZones)
+	count := s.remoteZones
+	if count > len(remoteZones) {
+		count = len(remoteZones)
+	}
+	for i := 0; i < count; i++ {
+		zone := remoteZones[((s.cycle-1)*count+i)%len(remoteZones)]
+		// move to the next peer of a zone every time the rotation over the zones wraps
+		round := ((s.cycle-1)*count + i) / len(remoteZones)
+		nodes = append(nodes, remote[zone][round%len(remote[zone])])
+	}
+	return nodes
+}
+
+// coverage is the ratio of peers probed within the coverage window.
+func (s *meshSelector) coverage(peers int) float64 {
+	if peers == 0 {
+		return 1
+	}
+	covered := 0
+	for _, t := range s.lastProbed {
+		if time.Since(t) <= s.coverageWindow {
+			covered++
+		}
+	}
+	return float64(covered) / float64(peers)
+}
+
+// fullCoverageCycles is the number of cycles the strategy needs to probe every peer,
+// zero when it does not guarantee to ever probe every peer.
+func (s *meshSelector) fullCoverageCycles(peers int) int {
+	switch s.strategy {
+	case MeshStrategyFull:
+		return 1
+	case MeshStrategyRandom:
+		if peers == 0 {
+			return 1
+		}
+		return (peers + s.peers - 1) / s.peers
+	default:
+		return 0
+	}
+}
+
+// nodeZones maps every node to the value of its topology label.
+func nodeZones(config *Configuration) (map[string]string, error) {
+	nodes, err := config.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("failed to list nodes, %v", err)
+		return nil, err
+	}
+	zones := make(map[string]string, len(nodes))
+	for _, no := range nodes {
+		zones[no.Name] = no.Labels[config.TopologyKey]
+	}
+	return zones, nil
+}
@@ -1,6 +1,12 @@
 package pinger
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"errors"
+	"math/big"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 var (
 	apiserverHealthyGauge = prometheus.NewGaugeVec(
@@ -53,6 +59,31 @@ var (
 		[]string{
 			"nodeName",
 		})
+	externalDNSHealthyGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_external_dns_healthy",
+			Help: "If the external dns request is healthy on this node",
+		},
+		[]string{
+			"nodeName",
+		})
+	externalDNSUnhealthyGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_external_dns_unhealthy",
+			Help: "If the external dns request is unhealthy on this node",
+		},
+		[]string{
+			"nodeName",
+		})
+	externalDNSRequestLatencyHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "pinger_external_dns_latency_ms",
+			Help:    "The latency ms histogram the node request external dns",
+			Buckets: []float64{2, 5, 10, 15, 20, 25, 30, 35, 40, 45, 50},
+		},
+		[]string{
+			"nodeName",
+		})
 	podPingLatencyHistogram = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "pinger_pod_ping_latency_ms",
@@ -158,25 +189,550 @@ var (
 			"src_pod_ip",
 			"target_ip",
 		})
+	externalPingLatencyHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "pinger_external_ping_latency_ms",
+			Help:    "The latency ms histogram for external address ping",
+			Buckets: []float64{.25, .5, 1, 2, 5, 10, 30, 50, 100},
+		},
+		[]string{
+			"src_node_name",
+			"src_node_ip",
+			"src_pod_ip",
+			"target_address",
+		})
+	externalPingLostCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pinger_external_ping_lost_total",
+			Help: "The lost count for external address ping",
+		}, []string{
+			"src_node_name",
+			"src_node_ip",
+			"src_pod_ip",
+			"target_address",
+		})
+	externalPingTotalCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pinger_external_ping_count_total",
+			Help: "The total count for external address ping",
+		}, []string{
+			"src_node_name",
+			"src_node_ip",
+			"src_pod_ip",
+			"target_address",
+		})
+	targetPingLatencyHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "pinger_target_ping_latency_ms",
+			Help:    "The latency ms histogram for ping target probes",
+			Buckets: []float64{.25, .5, 1, 2, 5, 10, 30},
+		},
+		[]string{
+			"src_node_name",
+			"src_node_ip",
+			"src_pod_ip",
+			"target",
+			"target_address",
+			"probe_type",
+		})
+	targetPingLostCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pinger_target_ping_lost_total",
+			Help: "The lost count for ping target probes",
+		},
+		[]string{
+			"src_node_name",
+			"src_node_ip",
+			"src_pod_ip",
+			"target",
+			"target_address",
+			"probe_type",
+		})
+	targetPingTotalCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pinger_target_ping_count_total",
+			Help: "The total count for ping target probes",
+		},
+		[]string{
+			"src_node_name",
+			"src_node_ip",
+			"src_pod_ip",
+			"target",
+			"target_address",
+			"probe_type",
+		})
+	targetHealthyGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_target_healthy",
+			Help: "If the last probe of the ping target address passed its thresholds",
+		},
+		[]string{
+			"src_node_name",
+			"src_node_ip",
+			"src_pod_ip",
+			"target",
+			"target_address",
+			"probe_type",
+		})
+	ipAuditFindingsGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_ip_audit_findings",
+			Help: "The number of ip allocation problems found by the last audit",
+		},
+		[]string{
+			"nodeName",
+			"type",
+		})
+	subnetTotalIPsGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_subnet_total_ips",
+			Help: "The number of usable addresses of the subnet, excluding excludeIps and the gateway",
+		},
+		[]string{
+			"subnet",
+			"protocol",
+			"cidr",
+		})
+	subnetAllocatedIPsGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_subnet_allocated_ips",
+			Help: "The number of addresses of the ipv4 blocks of the subnet allocated by ip crds",
+		},
+		[]string{
+			"subnet",
+			"protocol",
+			"cidr",
+		})
+	subnetFreeIPsGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_subnet_free_ips",
+			Help: "The number of usable addresses of the ipv4 blocks of the subnet not allocated yet",
+		},
+		[]string{
+			"subnet",
+			"protocol",
+			"cidr",
+		})
+	subnetUtilizationGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_subnet_utilization_ratio",
+			Help: "The ratio of allocated to usable addresses of the ipv4 blocks of the subnet",
+		},
+		[]string{
+			"subnet",
+			"protocol",
+			"cidr",
+		})
+	ipAuditCheckedGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_ip_audit_checked_ips",
+			Help: "The number of ip crds checked by the last audit",
+		},
+		[]string{
+			"nodeName",
+		})
+	meshLatencyHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "pinger_mesh_latency_ms",
+			Help:    "The latency ms histogram between the pingers of the source and the destination node, pod-to-pod (type pod) or host-to-host (type node)",
+			Buckets: []float64{.25, .5, 1, 2, 5, 10, 30},
+		},
+		[]string{
+			"src_node_name",
+			"dst_node_name",
+			"type",
+		})
+	meshLostCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pinger_mesh_lost_total",
+			Help: "The lost count between the pinger of the source node and the pinger of the destination node",
+		},
+		[]string{
+			"src_node_name",
+			"dst_node_name",
+			"type",
+		})
+	meshTotalCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pinger_mesh_count_total",
+			Help: "The total count between the pinger of the source node and the pinger of the destination node",
+		},
+		[]string{
+			"src_node_name",
+			"dst_node_name",
+			"type",
+		})
+	topologyLatencyHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "pinger_topology_latency_ms",
+			Help:    "The latency ms histogram aggregated from the topology of the source node to the topology of the target node, level is zone, region or an extra topology label",
+			Buckets: []float64{.25, .5, 1, 2, 5, 10, 30, 100},
+		},
+		[]string{
+			"level",
+			"src",
+			"dst",
+			"check",
+		})
+	topologyLostCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pinger_topology_lost_total",
+			Help: "The lost count aggregated from the topology of the source node to the topology of the target node",
+		},
+		[]string{
+			"level",
+			"src",
+			"dst",
+			"check",
+		})
+	topologyTotalCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pinger_topology_count_total",
+			Help: "The total count aggregated from the topology of the source node to the topology of the target node",
+		},
+		[]string{
+			"level",
+			"src",
+			"dst",
+			"check",
+		})
+	tracerouteLastHopGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_traceroute_last_hop_ttl",
+			Help: "The ttl of the farthest hop that answered the last traceroute to a failing target, 0 if no hop answered",
+		},
+		[]string{
+			"src_node_name",
+			"check",
+			"target",
+			"target_address",
+			"last_hop",
+		})
+	tracerouteReachedGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_traceroute_reached",
+			Help: "If the last traceroute to a failing target reached it",
+		},
+		[]string{
+			"src_node_name",
+			"check",
+			"target",
+			"target_address",
+		})
+	pathMTUGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_path_mtu_bytes",
+			Help: "The path mtu discovered towards a target",
+		},
+		[]string{
+			"src_node_name",
+			"check",
+			"target",
+			"target_address",
+			"interface",
+		})
+	interfaceMTUGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_interface_mtu_bytes",
+			Help: "The mtu of the local interface the route to a target leaves through",
+		},
+		[]string{
+			"src_node_name",
+			"check",
+			"target",
+			"target_address",
+			"interface",
+		})
+	mtuMismatchGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_mtu_mismatch",
+			Help: "If the path mtu towards a target is lower than the mtu of the local interface",
+		},
+		[]string{
+			"src_node_name",
+			"check",
+			"target",
+			"target_address",
+			"interface",
+		})
+	dscpLatencyHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "pinger_dscp_latency_ms",
+			Help:    "The latency ms histogram of icmp probes marked with a dscp class",
+			Buckets: []float64{.25, .5, 1, 2, 5, 10, 30},
+		},
+		[]string{
+			"src_node_name",
+			"check",
+			"target",
+			"target_address",
+			"dscp",
+		})
+	dscpLostCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pinger_dscp_lost_total",
+			Help: "The lost count of icmp probes marked with a dscp class",
+		},
+		[]string{
+			"src_node_name",
+			"check",
+			"target",
+			"target_address",
+			"dscp",
+		})
+	dscpTotalCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pinger_dscp_count_total",
+			Help: "The total count of icmp probes marked with a dscp class",
+		},
+		[]string{
+			"src_node_name",
+			"check",
+			"target",
+			"target_address",
+			"dscp",
+		})
+	dscpReplyGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_dscp_reply_dscp",
+			Help: "The dscp the last echo reply to probes marked with a dscp class came back with",
+		},
+		[]string{
+			"src_node_name",
+			"check",
+			"target",
+			"target_address",
+			"dscp",
+		})
+	dscpRemarkedGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_dscp_remarked",
+			Help: "If echo replies to probes marked with a dscp class came back with another dscp",
+		},
+		[]string{
+			"src_node_name",
+			"check",
+			"target",
+			"target_address",
+			"dscp",
+		})
+	meshPeersGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_mesh_peers",
+			Help: "The number of mesh peers known to the pinger of the node",
+		},
+		[]string{
+			"src_node_name",
+		})
+	meshSelectedPeersGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_mesh_selected_peers",
+			Help: "The number of mesh peers probed in the last cycle",
+		},
+		[]string{
+			"src_node_name",
+		})
+	meshCoverageGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_mesh_coverage_ratio",
+			Help: "The ratio of mesh peers probed within the coverage window",
+		},
+		[]string{
+			"src_node_name",
+		})
+	meshFullCoverageCyclesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_mesh_full_coverage_cycles",
+			Help: "The number of cycles the mesh strategy needs to probe every peer, 0 if it never probes every peer",
+		},
+		[]string{
+			"src_node_name",
+		})
+	meshReachableGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_mesh_reachable",
+			Help: "If the last probe from the source node to the destination node got every reply",
+		},
+		[]string{
+			"src_node_name",
+			"dst_node_name",
+			"type",
+		})
+	exporterBufferedGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pinger_exporter_buffered_lines",
+			Help: "The number of result lines an exporter keeps until its collector accepts them",
+		},
+		[]string{
+			"exporter",
+		})
+	exporterDroppedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pinger_exporter_dropped_lines_total",
+			Help: "The number of result lines an exporter dropped because its buffer was full or its collector rejected them",
+		},
+		[]string{
+			"exporter",
+		})
 )
 
 func InitPingerMetrics() {
-	prometheus.MustRegister(apiserverHealthyGauge)
-	prometheus.MustRegister(apiserverUnhealthyGauge)
-	prometheus.MustRegister(apiserverRequestLatencyHistogram)
-	prometheus.MustRegister(internalDNSHealthyGauge)
-	prometheus.MustRegister(internalDNSUnhealthyGauge)
-	prometheus.MustRegister(internalDNSRequestLatencyHistogram)
-	prometheus.MustRegister(podPingLatencyHistogram)
-	prometheus.MustRegister(podPingLostCounter)
-	prometheus.MustRegister(podPingTotalCounter)
-	prometheus.MustRegister(nodePingLatencyHistogram)
-	prometheus.MustRegister(nodePingLostCounter)
-	prometheus.MustRegister(nodePingTotalCounter)
-	prometheus.MustRegister(IpPingLatencyHistogram)
-	prometheus.MustRegister(IpPingLostCounter)
-	prometheus.MustRegister(IpPingTotalCounter)
+	for _, collector := range pingerCollectors() {
+		prometheus.MustRegister(collector)
+	}
+}
+
+// RegisterPingerMetrics registers the pinger metrics on the registerer of a program embedding
+// the pinger. The collectors are package globals, registering them twice on the same registerer
+// is fine, while another collector of the same name is an error.
+func RegisterPingerMetrics(registerer prometheus.Registerer) error {
+	for _, collector := range pingerCollectors() {
+		if err := registerer.Register(collector); err != nil {
+			var registered prometheus.AlreadyRegisteredError
+			if !errors.As(err, &registered) || registered.ExistingCollector != collector {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func pingerCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		apiserverHealthyGauge,
+		apiserverUnhealthyGauge,
+		apiserverRequestLatencyHistogram,
+		internalDNSHealthyGauge,
+		internalDNSUnhealthyGauge,
+		internalDNSRequestLatencyHistogram,
+		externalDNSHealthyGauge,
+		externalDNSUnhealthyGauge,
+		externalDNSRequestLatencyHistogram,
+		podPingLatencyHistogram,
+		podPingLostCounter,
+		podPingTotalCounter,
+		nodePingLatencyHistogram,
+		nodePingLostCounter,
+		nodePingTotalCounter,
+		IpPingLatencyHistogram,
+		IpPingLostCounter,
+		IpPingTotalCounter,
+		externalPingLatencyHistogram,
+		externalPingLostCounter,
+		externalPingTotalCounter,
+		targetPingLatencyHistogram,
+		targetPingLostCounter,
+		targetPingTotalCounter,
+		targetHealthyGauge,
+		ipAuditFindingsGauge,
+		ipAuditCheckedGauge,
+		subnetTotalIPsGauge,
+		subnetAllocatedIPsGauge,
+		subnetFreeIPsGauge,
+		subnetUtilizationGauge,
+		meshLatencyHistogram,
+		meshLostCounter,
+		meshTotalCounter,
+		meshReachableGauge,
+		meshPeersGauge,
+		meshSelectedPeersGauge,
+		meshCoverageGauge,
+		meshFullCoverageCyclesGauge,
+		topologyLatencyHistogram,
+		topologyLostCounter,
+		topologyTotalCounter,
+		tracerouteLastHopGauge,
+		tracerouteReachedGauge,
+		pathMTUGauge,
+		interfaceMTUGauge,
+		mtuMismatchGauge,
+		dscpLatencyHistogram,
+		dscpLostCounter,
+		dscpTotalCounter,
+		dscpReplyGauge,
+		dscpRemarkedGauge,
+		exporterBufferedGauge,
+		exporterDroppedCounter,
+	}
+}
 
+func SetPodPingMetrics(srcNodeName, srcNodeIP, srcPodIP, targetNodeName, targetNodeIP, targetPodIP string, latency float64, lost, total int) {
+	podPingLatencyHistogram.WithLabelValues(
+		srcNodeName,
+		srcNodeIP,
+		srcPodIP,
+		targetNodeName,
+		targetNodeIP,
+		targetPodIP,
+	).Observe(latency)
+	podPingLostCounter.WithLabelValues(
+		srcNodeName,
+		srcNodeIP,
+		srcPodIP,
+		targetNodeName,
+		targetNodeIP,
+		targetPodIP,
+	).Add(float64(lost))
+	podPingTotalCounter.WithLabelValues(
+		srcNodeName,
+		srcNodeIP,
+		srcPodIP,
+		targetNodeName,
+		targetNodeIP,
+		targetPodIP,
+	).Add(float64(total))
+}
+
+func SetIPPingMetrics(srcNodeName, srcNodeIP, srcPodIP, targetIP string, latency float64, lost, total int) {
+	IpPingLatencyHistogram.WithLabelValues(
+		srcNodeName,
+		srcNodeIP,
+		srcPodIP,
+		targetIP,
+	).Observe(latency)
+	IpPingLostCounter.WithLabelValues(
+		srcNodeName,
+		srcNodeIP,
+		srcPodIP,
+		targetIP,
+	).Add(float64(lost))
+	IpPingTotalCounter.WithLabelValues(
+		srcNodeName,
+		srcNodeIP,
+		srcPodIP,
+		targetIP,
+	).Add(float64(total))
+}
+
+func SetExternalPingMetrics(srcNodeName, srcNodeIP, srcPodIP, targetAddress string, latency float64, lost, total int) {
+	labels := []string{srcNodeName, srcNodeIP, srcPodIP, targetAddress}
+	externalPingLatencyHistogram.WithLabelValues(labels...).Observe(latency)
+	externalPingLostCounter.WithLabelValues(labels...).Add(float64(lost))
+	externalPingTotalCounter.WithLabelValues(labels...).Add(float64(total))
+}
+
+func SetNodePingMetrics(srcNodeName, srcNodeIP, srcPodIP, targetNodeName, targetNodeIP string, latency float64, lost, total int) {
+	nodePingLatencyHistogram.WithLabelValues(
+		srcNodeName,
+		srcNodeIP,
+		srcPodIP,
+		targetNodeName,
+		targetNodeIP,
+	).Observe(latency)
+	nodePingLostCounter.WithLabelValues(
+		srcNodeName,
+		srcNodeIP,
+		srcPodIP,
+		targetNodeName,
+		targetNodeIP,
+	).Add(float64(lost))
+	nodePingTotalCounter.WithLabelValues(
+		srcNodeName,
+		srcNodeIP,
+		srcPodIP,
+		targetNodeName,
+		targetNodeIP,
+	).Add(float64(total))
 }
 
 func SetApiserverUnhealthyMetrics(nodeName string) {
@@ -199,4 +755,178 @@ func SetInternalDNSHealthyMetrics(nodeName string, latency float64) {
 func SetInternalDNSUnhealthyMetrics(nodeName string) {
 	internalDNSHealthyGauge.WithLabelValues(nodeName).Set(0)
 	internalDNSUnhealthyGauge.WithLabelValues(nodeName).Set(1)
-}
\ No newline at end of file
+}
+
+func SetExternalDNSHealthyMetrics(nodeName string, latency float64) {
+	externalDNSHealthyGauge.WithLabelValues(nodeName).Set(1)
+	externalDNSRequestLatencyHistogram.WithLabelValues(nodeName).Observe(latency)
+	externalDNSUnhealthyGauge.WithLabelValues(nodeName).Set(0)
+}
+
+func SetExternalDNSUnhealthyMetrics(nodeName string) {
+	externalDNSHealthyGauge.WithLabelValues(nodeName).Set(0)
+	externalDNSUnhealthyGauge.WithLabelValues(nodeName).Set(1)
+}
+
+func SetIPAuditMetrics(report *AuditReport) {
+	counts := make(map[string]int, len(auditFindingTypes))
+	for _, f := range report.Findings {
+		counts[f.Type]++
+	}
+	for _, t := range auditFindingTypes {
+		ipAuditFindingsGauge.WithLabelValues(report.NodeName, t).Set(float64(counts[t]))
+	}
+	ipAuditCheckedGauge.WithLabelValues(report.NodeName).Set(float64(report.CheckedIPs))
+}
+
+// ResetSubnetMetrics drops the series of subnets that have been deleted since the last collection.
+func ResetSubnetMetrics() {
+	subnetTotalIPsGauge.Reset()
+	subnetAllocatedIPsGauge.Reset()
+	subnetFreeIPsGauge.Reset()
+	subnetUtilizationGauge.Reset()
+}
+
+func SetSubnetMetrics(usage SubnetUsage) {
+	total, _ := new(big.Float).SetInt(usage.Total).Float64()
+	free, _ := new(big.Float).SetInt(usage.Free()).Float64()
+	subnetTotalIPsGauge.WithLabelValues(usage.Subnet, usage.Protocol, usage.CIDR).Set(total)
+	if usage.AllocatedUnknown {
+		return
+	}
+	subnetAllocatedIPsGauge.WithLabelValues(usage.Subnet, usage.Protocol, usage.CIDR).Set(float64(usage.Allocated))
+	subnetFreeIPsGauge.WithLabelValues(usage.Subnet, usage.Protocol, usage.CIDR).Set(free)
+	subnetUtilizationGauge.WithLabelValues(usage.Subnet, usage.Protocol, usage.CIDR).Set(usage.Utilization())
+}
+
+func SetTargetPingMetrics(srcNodeName, srcNodeIP, srcPodIP, target, targetAddress, probeType string, latency float64, lost, total int, healthy bool) {
+	labels := []string{srcNodeName, srcNodeIP, srcPodIP, target, targetAddress, probeType}
+	targetPingLatencyHistogram.WithLabelValues(labels...).Observe(latency)
+	targetPingLostCounter.WithLabelValues(labels...).Add(float64(lost))
+	targetPingTotalCounter.WithLabelValues(labels...).Add(float64(total))
+	if healthy {
+		targetHealthyGauge.WithLabelValues(labels...).Set(1)
+	} else {
+		targetHealthyGauge.WithLabelValues(labels...).Set(0)
+	}
+}
+
+// DeleteTargetPingMetrics drops the series of a ping target that was changed or deleted.
+func DeleteTargetPingMetrics(target string) {
+	match := prometheus.Labels{"target": target}
+	targetPingLatencyHistogram.DeletePartialMatch(match)
+	targetPingLostCounter.DeletePartialMatch(match)
+	targetPingTotalCounter.DeletePartialMatch(match)
+	targetHealthyGauge.DeletePartialMatch(match)
+}
+
+// DeleteTargetAddressPingMetrics drops the series of an address no longer selected by the ping target.
+func DeleteTargetAddressPingMetrics(target, address string) {
+	match := prometheus.Labels{"target": target, "target_address": address}
+	targetPingLatencyHistogram.DeletePartialMatch(match)
+	targetPingLostCounter.DeletePartialMatch(match)
+	targetPingTotalCounter.DeletePartialMatch(match)
+	targetHealthyGauge.DeletePartialMatch(match)
+}
+
+func SetMeshMetrics(srcNodeName, dstNodeName, meshType string, latency float64, lost, total int) {
+	meshLatencyHistogram.WithLabelValues(srcNodeName, dstNodeName, meshType).Observe(latency)
+	meshLostCounter.WithLabelValues(srcNodeName, dstNodeName, meshType).Add(float64(lost))
+	meshTotalCounter.WithLabelValues(srcNodeName, dstNodeName, meshType).Add(float64(total))
+	if lost == 0 && total != 0 {
+		meshReachableGauge.WithLabelValues(srcNodeName, dstNodeName, meshType).Set(1)
+	} else {
+		meshReachableGauge.WithLabelValues(srcNodeName, dstNodeName, meshType).Set(0)
+	}
+}
+
+func SetMeshUnreachableMetrics(srcNodeName, dstNodeName, meshType string) {
+	meshReachableGauge.WithLabelValues(srcNodeName, dstNodeName, meshType).Set(0)
+}
+
+func SetMeshCoverageMetrics(srcNodeName string, peers, selected int, coverage float64, fullCoverageCycles int) {
+	meshPeersGauge.WithLabelValues(srcNodeName).Set(float64(peers))
+	meshSelectedPeersGauge.WithLabelValues(srcNodeName).Set(float64(selected))
+	meshCoverageGauge.WithLabelValues(srcNodeName).Set(coverage)
+	meshFullCoverageCyclesGauge.WithLabelValues(srcNodeName).Set(float64(fullCoverageCycles))
+}
+
+// DeleteMeshMetrics drops the series towards a node whose pinger is gone.
+func DeleteMeshMetrics(dstNodeName string) {
+	match := prometheus.Labels{"dst_node_name": dstNodeName}
+	meshLatencyHistogram.DeletePartialMatch(match)
+	meshLostCounter.DeletePartialMatch(match)
+	meshTotalCounter.DeletePartialMatch(match)
+	meshReachableGauge.DeletePartialMatch(match)
+}
+
+// SetTopologyMetrics leaves the latency out when every packet was lost, the rtt of such a probe
+// is zero and would make a partitioned pair look faster than a healthy one.
+func SetTopologyMetrics(level, src, dst, check string, latency float64, lost, total int) {
+	if lost < total {
+		topologyLatencyHistogram.WithLabelValues(level, src, dst, check).Observe(latency)
+	}
+	topologyLostCounter.WithLabelValues(level, src, dst, check).Add(float64(lost))
+	topologyTotalCounter.WithLabelValues(level, src, dst, check).Add(float64(total))
+}
+
+func SetTracerouteMetrics(srcNodeName, check, target, targetAddress, lastHop string, lastTTL int, reached bool) {
+	DeleteTracerouteMetrics(check, targetAddress)
+	tracerouteLastHopGauge.WithLabelValues(srcNodeName, check, target, targetAddress, lastHop).Set(float64(lastTTL))
+	if reached {
+		tracerouteReachedGauge.WithLabelValues(srcNodeName, check, target, targetAddress).Set(1)
+	} else {
+		tracerouteReachedGauge.WithLabelValues(srcNodeName, check, target, targetAddress).Set(0)
+	}
+}
+
+// DeleteTracerouteMetrics drops the traceroute series of a target that is reachable again.
+func DeleteTracerouteMetrics(check, targetAddress string) {
+	match := prometheus.Labels{"check": check, "target_address": targetAddress}
+	tracerouteLastHopGauge.DeletePartialMatch(match)
+	tracerouteReachedGauge.DeletePartialMatch(match)
+}
+
+func SetPathMTUMetrics(srcNodeName, check, target, targetAddress, iface string, pathMTU, localMTU int) {
+	// the route to the target may have moved to another interface
+	match := prometheus.Labels{"check": check, "target_address": targetAddress}
+	pathMTUGauge.DeletePartialMatch(match)
+	interfaceMTUGauge.DeletePartialMatch(match)
+	mtuMismatchGauge.DeletePartialMatch(match)
+
+	pathMTUGauge.WithLabelValues(srcNodeName, check, target, targetAddress, iface).Set(float64(pathMTU))
+	interfaceMTUGauge.WithLabelValues(srcNodeName, check, target, targetAddress, iface).Set(float64(localMTU))
+	if pathMTU < localMTU {
+		mtuMismatchGauge.WithLabelValues(srcNodeName, check, target, targetAddress, iface).Set(1)
+	} else {
+		mtuMismatchGauge.WithLabelValues(srcNodeName, check, target, targetAddress, iface).Set(0)
+	}
+}
+
+func SetDSCPMetrics(srcNodeName, check, target, targetAddress string, dscp int, latency float64, lost, total int) {
+	labels := []string{srcNodeName, check, target, targetAddress, strconv.Itoa(dscp)}
+	if total != lost {
+		dscpLatencyHistogram.WithLabelValues(labels...).Observe(latency)
+	}
+	dscpLostCounter.WithLabelValues(labels...).Add(float64(lost))
+	dscpTotalCounter.WithLabelValues(labels...).Add(float64(total))
+}
+
+// SetDSCPReplyMetrics exports the marking of the echo replies, only called when it could be read.
+func SetDSCPReplyMetrics(srcNodeName, check, target, targetAddress string, dscp, replyDSCP int, remarked bool) {
+	labels := []string{srcNodeName, check, target, targetAddress, strconv.Itoa(dscp)}
+	dscpReplyGauge.WithLabelValues(labels...).Set(float64(replyDSCP))
+	if remarked {
+		dscpRemarkedGauge.WithLabelValues(labels...).Set(1)
+	} else {
+		dscpRemarkedGauge.WithLabelValues(labels...).Set(0)
+	}
+}
+
+func SetExporterBufferedMetrics(exporter string, buffered int) {
+	exporterBufferedGauge.WithLabelValues(exporter).Set(float64(buffered))
+}
+
+func AddExporterDroppedMetrics(exporter string, dropped int) {
+	exporterDroppedCounter.WithLabelValues(exporter).Add(float64(dropped))
+}
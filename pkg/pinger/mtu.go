@@ -0,0 +1,96 @@
+package pinger
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/wenwenxiong/network-pinger/pkg/pmtu"
+)
+
+// pmtuManager discovers the path mtu of every reachable target once per interval,
+// one target after the other in the background so the probe cycle is never delayed.
+type pmtuManager struct {
+	opts     pmtu.Options
+	interval time.Duration
+
+	mu      sync.Mutex
+	running bool
+	last    map[string]time.Time
+}
+
+func newPMTUManager(config *Configuration) *pmtuManager {
+	return &pmtuManager{
+		opts:     pmtu.Options{Timeout: config.PMTUTimeout, Retries: config.PMTURetries},
+		interval: config.PMTUInterval,
+		last:     make(map[string]time.Time),
+	}
+}
+
+func (m *pmtuManager) schedule(ctx context.Context, config *Configuration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running {
+		return
+	}
+
+	var due []TargetState
+	known := make(map[string]bool)
+	for _, state := range config.results.snapshot(config.resultTTL()) {
+		known[state.Key()] = true
+		switch state.Check {
+		case CheckPod, CheckNode, CheckIP, CheckTarget, CheckExternal:
+		default:
+			continue
+		}
+		if state.Address == "" || !state.Healthy || time.Since(m.last[state.Key()]) < m.interval {
+			continue
+		}
+		due = append(due, state)
+	}
+	for key := range m.last {
+		if !known[key] {
+			delete(m.last, key)
+		}
+	}
+	if len(due) == 0 {
+		return
+	}
+	m.running = true
+
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			m.running = false
+			m.mu.Unlock()
+		}()
+		klog.Infof("start to discover path mtu of %d targets", len(due))
+		for _, state := range due {
+			if ctx.Err() != nil {
+				return
+			}
+			m.mu.Lock()
+			m.last[state.Key()] = time.Now()
+			m.mu.Unlock()
+			discoverPathMTU(config, state.ProbeResult, m.opts)
+		}
+	}()
+}
+
+func discoverPathMTU(config *Configuration, r ProbeResult, opts pmtu.Options) {
+	result, err := pmtu.Discover(r.Address, opts)
+	if err != nil {
+		klog.Errorf("failed to discover path mtu of %s %s: %v", r.Check, targetDisplayName(r), err)
+		return
+	}
+	config.results.setPathMTU(r.Key(), result)
+	if result.Mismatch() {
+		klog.Warningf("path mtu of %s %s is %d, lower than mtu %d of interface %s, reported by router %v",
+			r.Check, targetDisplayName(r), result.PathMTU, result.LocalMTU, result.Interface, result.Reported)
+	} else {
+		klog.Infof("path mtu of %s %s is %d, interface %s", r.Check, targetDisplayName(r), result.PathMTU, result.Interface)
+	}
+	SetPathMTUMetrics(config.NodeName, r.Check, targetDisplayName(r), r.Address, result.Interface, result.PathMTU, result.LocalMTU)
+}
@@ -0,0 +1,185 @@
+package pinger
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"github.com/wenwenxiong/network-pinger/pkg/otlp"
+	"github.com/wenwenxiong/network-pinger/versions"
+)
+
+// maxPendingSpans bounds the spans kept between two exports, the newest ones are dropped.
+const maxPendingSpans = 10000
+
+var otlpScope = otlp.Scope{Name: "github.com/wenwenxiong/network-pinger", Version: versions.VERSION}
+
+// otelExporter exports the metrics gathered for prometheus over otlp and, with traces enabled,
+// a span per probe cycle with a child span per probed target.
+type otelExporter struct {
+	config   *Configuration
+	client   *otlp.Client
+	resource []otlp.Attribute
+	start    time.Time
+	traces   bool
+
+	mu      sync.Mutex
+	cycle   *otlp.Span
+	pending []otlp.Span
+}
+
+func newOTelExporter(config *Configuration) (*otelExporter, error) {
+	client, err := otlp.NewClient(otlp.Options{
+		Endpoint: config.OTLPEndpoint,
+		Protocol: config.OTLPProtocol,
+		Headers:  config.OTLPHeaders,
+		Timeout:  config.OTLPTimeout,
+	})
+	if err != nil {
+		klog.Errorf("failed to create otlp client: %v", err)
+		return nil, err
+	}
+	return &otelExporter{
+		config: config,
+		client: client,
+		resource: []otlp.Attribute{
+			otlp.String("service.name", "network-pinger"),
+			otlp.String("service.version", versions.VERSION),
+			otlp.String("k8s.node.name", config.NodeName),
+			otlp.String("k8s.pod.name", config.PodName),
+			otlp.String("k8s.namespace.name", config.DaemonSetNamespace),
+		},
+		start:  time.Now(),
+		traces: config.EnableOTLPTraces,
+	}, nil
+}
+
+// run exports the metrics every interval until stopCh is closed, the last export is done
+// by the shutdown with the other pushes.
+func (e *otelExporter) run(stopCh <-chan struct{}) {
+	go wait.Until(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), e.config.OTLPTimeout)
+		defer cancel()
+		_ = e.exportMetrics(ctx)
+	}, e.config.OTLPInterval, stopCh)
+}
+
+func (e *otelExporter) exportMetrics(ctx context.Context) error {
+	families, err := e.config.gatherer.Gather()
+	if err != nil {
+		klog.Errorf("failed to gather metrics for otlp: %v", err)
+		return err
+	}
+	if err = e.client.ExportMetrics(ctx, e.resource, otlpScope, families, e.start, time.Now()); err != nil {
+		klog.Errorf("failed to export metrics to otlp collector %s: %v", e.config.OTLPEndpoint, err)
+		return err
+	}
+	klog.V(3).Infof("exported metrics to otlp collector %s", e.config.OTLPEndpoint)
+	return nil
+}
+
+// startCycle opens the span the results of the cycle are attached to.
+func (e *otelExporter) startCycle() {
+	if !e.traces {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.cycle = &otlp.Span{
+		TraceID:    otlp.NewTraceID(),
+		SpanID:     otlp.NewSpanID(),
+		Name:       "probe cycle",
+		Kind:       otlp.SpanKindInternal,
+		Start:      time.Now(),
+		Attributes: []otlp.Attribute{otlp.String("src_node_name", e.config.NodeName), otlp.String("mode", e.config.Mode)},
+	}
+}
+
+// endCycle closes the span of the cycle, failed when a check failed, and exports it with the
+// spans of its targets and of the declared targets probed meanwhile.
+func (e *otelExporter) endCycle(failures map[string]error) {
+	if !e.traces {
+		return
+	}
+	e.mu.Lock()
+	if cycle := e.cycle; cycle != nil {
+		cycle.End = time.Now()
+		cycle.Status = otlp.StatusOK
+		if len(failures) != 0 {
+			checks := make([]string, 0, len(failures))
+			for check := range failures {
+				checks = append(checks, check)
+			}
+			sort.Strings(checks)
+			cycle.Status, cycle.StatusMessage = otlp.StatusError, "failed checks: "+strings.Join(checks, ", ")
+		}
+		e.pending = append(e.pending, *cycle)
+		e.cycle = nil
+	}
+	spans := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.config.OTLPTimeout)
+	defer cancel()
+	if err := e.client.ExportSpans(ctx, e.resource, otlpScope, spans); err != nil {
+		klog.Errorf("failed to export %d spans to otlp collector %s: %v", len(spans), e.config.OTLPEndpoint, err)
+	}
+}
+
+// Record turns the result into a span, a child of the running cycle unless it comes from the
+// worker of a declared target, which runs on its own schedule and gets a trace of its own.
+func (e *otelExporter) Record(r ProbeResult) {
+	if !e.traces {
+		return
+	}
+	span := otlp.Span{
+		SpanID:     otlp.NewSpanID(),
+		Name:       r.Check + " " + targetDisplayName(r),
+		Kind:       otlp.SpanKindClient,
+		Start:      r.Started,
+		End:        r.Timestamp,
+		Attributes: e.spanAttributes(r),
+		Status:     otlp.StatusOK,
+	}
+	if span.Start.IsZero() {
+		span.Start = span.End
+	}
+	if !r.Healthy {
+		span.Status, span.StatusMessage = otlp.StatusError, r.Error
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cycle != nil && r.Check != CheckTarget {
+		span.TraceID, span.ParentID = e.cycle.TraceID, e.cycle.SpanID
+	} else {
+		span.TraceID = otlp.NewTraceID()
+	}
+	if len(e.pending) < maxPendingSpans {
+		e.pending = append(e.pending, span)
+	}
+}
+
+// spanAttributes carries the source and target dimensions of the prometheus labels.
+func (e *otelExporter) spanAttributes(r ProbeResult) []otlp.Attribute {
+	dimensions := e.config.resultDimensions(r)
+	attrs := make([]otlp.Attribute, 0, len(dimensions)+4)
+	for _, d := range dimensions {
+		attrs = append(attrs, otlp.String(d.name, d.value))
+	}
+	return append(attrs,
+		otlp.Int("sent", r.Sent),
+		otlp.Int("lost", r.Lost),
+		otlp.Float("avg_rtt_ms", float64(r.AvgRTT)/float64(time.Millisecond)),
+		otlp.Bool("healthy", r.Healthy),
+	)
+}
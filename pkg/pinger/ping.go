@@ -3,59 +3,185 @@ package pinger
 import (
 	"context"
 	"fmt"
-	goping "github.com/prometheus-community/pro-bing"
 	"github.com/wenwenxiong/network-pinger/pkg/util"
 	v1 "k8s.io/api/core/v1"
-	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/klog/v2"
 	"math"
 	"net"
-	"os"
 	"strings"
 	"time"
 )
 
-func StartPinger(config *Configuration) {
-	errHappens := false
+// StartPinger probes until the context is canceled, then flushes the latest results and metrics.
+// It returns the exit code of the process, job mode returns after a single cycle.
+func StartPinger(ctx context.Context, config *Configuration) int {
+	stopCh := ctx.Done()
+	config.done = ctx.Done()
+	config.initNetworkInformers()
+	config.initKubeInformers()
+	if config.EnablePingTargets && config.Mode == "server" {
+		// job mode probes the targets within the cycle so the report covers them
+		newTargetController(config).run(stopCh)
+	}
+	config.networkInformerFactory.Start(stopCh)
+	config.kubeInformerFactory.Start(stopCh)
+	for informerType, synced := range config.networkInformerFactory.WaitForCacheSync(stopCh) {
+		if !synced {
+			klog.Errorf("failed to sync %v informer", informerType)
+		}
+	}
+	for informerType, synced := range config.kubeInformerFactory.WaitForCacheSync(stopCh) {
+		if !synced {
+			klog.Errorf("failed to sync %v informer", informerType)
+		}
+	}
+	if config.notifier != nil {
+		config.notifier.Run(stopCh)
+	}
+	if config.reloader != nil {
+		config.reloader.run(stopCh)
+	}
+	if config.otel != nil && config.Mode == "server" {
+		config.otel.run(stopCh)
+	}
+	for _, exporter := range config.exporters {
+		exporter.run(stopCh)
+	}
+	if config.history != nil && config.Mode == "server" {
+		config.history.run(stopCh, config.HistorySaveInterval)
+	}
+
 	for {
-		if ping(config) != nil {
-			errHappens = true
+		if config.reloader != nil {
+			if next := config.reloader.next(); next != nil {
+				config.reload(next)
+			}
+		}
+		startTime := time.Now()
+		if config.otel != nil {
+			config.otel.startCycle()
+		}
+		failures := ping(ctx, config)
+		if config.otel != nil {
+			config.otel.endCycle(failures)
 		}
 
 		if config.Mode != "server" {
-			break
+			report := buildReport(config, startTime, failures)
+			if config.ReportFormat != "" {
+				if err := writeReport(config.ReportFormat, config.ReportOutput, report); err != nil {
+					klog.Errorf("failed to write %s report to %s: %v", config.ReportFormat, config.ReportOutput, err)
+				}
+			}
+			// the metrics server only runs in server mode
+			flushCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+			_ = config.pushMetrics(flushCtx)
+			config.flushExporters(flushCtx)
+			cancel()
+			config.saveHistory()
+			return config.exitCode(report)
+		}
+
+		select {
+		case <-ctx.Done():
+			config.shutdown()
+			return 0
+		case <-time.After(config.interval()):
 		}
+	}
+}
 
-		time.Sleep(time.Duration(config.Interval) * time.Second)
+// shutdown publishes what the last cycles saw, bounded by the shutdown timeout so the pod
+// exits within its termination grace period.
+func (config *Configuration) shutdown() {
+	klog.Infof("pinger is shutting down, flush the latest results and metrics")
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if config.pingResultEnabled() {
+		_ = updatePingResult(ctx, config)
 	}
-	if errHappens && config.ExitCode != 0 {
-		os.Exit(config.ExitCode)
+	_ = config.pushMetrics(ctx)
+	config.flushExporters(ctx)
+	config.saveHistory()
+}
+
+// saveHistory keeps the history of the targets for the next pinger, job mode saves it after
+// every run so the history spans the runs.
+func (config *Configuration) saveHistory() {
+	if config.history != nil {
+		_ = config.history.save()
 	}
 }
 
-func ping(config *Configuration) error {
-	errHappens := false
-	if checkAPIServer(config) != nil {
-		errHappens = true
+// ping runs one cycle of every check and returns the error of each check that failed.
+func ping(ctx context.Context, config *Configuration) map[string]error {
+	failures := make(map[string]error)
+	// stale topology only affects the aggregated metrics
+	_ = config.topology.refresh(config.nodeLister)
+	if err := checkAPIServer(config); err != nil {
+		failures[CheckAPIServer] = err
+	}
+	if err := pingPods(ctx, config); err != nil {
+		failures[CheckPod] = err
 	}
-	if pingPods(config) != nil {
-		errHappens = true
+	if config.EnableMesh {
+		// the mesh between the pingers replaces probing every node
+		pingMesh(ctx, config, failures)
+	} else if err := pingNodes(ctx, config); err != nil {
+		failures[CheckNode] = err
 	}
-	if pingNodes(config) != nil {
-		errHappens = true
+	if err := internalNslookup(ctx, config); err != nil {
+		failures[CheckDNS] = err
 	}
-	if internalNslookup(config) != nil {
-		errHappens = true
+	if config.externalDNS() != "" {
+		if err := externalNslookup(ctx, config); err != nil {
+			failures[CheckExtDNS] = err
+		}
 	}
 
-	if pingIPs(config) != nil {
-		errHappens = true
+	if err := pingIPs(ctx, config); err != nil {
+		failures[CheckIP] = err
 	}
 
-	if errHappens {
-		return fmt.Errorf("ping failed")
+	if config.externalAddress() != "" {
+		if err := pingExternal(ctx, config); err != nil {
+			failures[CheckExternal] = err
+		}
 	}
-	return nil
+
+	if config.EnablePingTargets && config.Mode != "server" {
+		if err := pingTargets(ctx, config); err != nil {
+			failures[CheckTarget] = err
+		}
+	}
+
+	if config.ipAuditEnabled() {
+		if err := auditIPs(ctx, config); err != nil {
+			failures[CheckIPAudit] = err
+		}
+	}
+
+	if config.EnableSubnetMetrics {
+		if err := collectSubnetMetrics(config); err != nil {
+			failures[CheckSubnet] = err
+		}
+	}
+
+	if config.pmtu != nil {
+		config.pmtu.schedule(ctx, config)
+	}
+	if config.traces != nil {
+		config.traces.prune(config.results.snapshot(config.resultTTL()))
+	}
+
+	if config.pingResultEnabled() {
+		// failing to publish the summary does not mean the network is broken
+		_ = updatePingResult(ctx, config)
+	}
+
+	return failures
 }
 
 func checkAPIServer(config *Configuration) error {
@@ -65,264 +191,206 @@ func checkAPIServer(config *Configuration) error {
 	elapsed := time.Since(t1)
 	if err != nil {
 		klog.Errorf("failed to connect to apiserver: %v", err)
-		SetApiserverUnhealthyMetrics(config.NodeName)
+		config.recordResult(ProbeResult{Check: CheckAPIServer, Name: "kubernetes", Started: t1, Error: err.Error()})
 		return err
 	}
-	klog.Infof("connect to apiserver success in %.2fms", float64(elapsed)/float64(time.Millisecond))
-	SetApiserverHealthyMetrics(config.NodeName, float64(elapsed)/float64(time.Millisecond))
+	config.recordResult(ProbeResult{Check: CheckAPIServer, Name: "kubernetes", Started: t1, AvgRTT: elapsed, Healthy: true})
 	return nil
 }
 
-func pingPods(config *Configuration) error {
+func pingPods(ctx context.Context, config *Configuration) error {
 	klog.Infof("start to check pod connectivity")
-	pods, err := config.KubeClient.CoreV1().Pods(config.DestNamespace).List(context.Background(), metav1.ListOptions{LabelSelector: config.MatchLabels})
+	selector, err := labels.Parse(config.MatchLabels)
+	if err != nil {
+		klog.Errorf("invalid pod selector %q: %v", config.MatchLabels, err)
+		return err
+	}
+	pods, err := config.podLister.Pods(config.destNamespace()).List(selector)
 	if err != nil {
 		klog.Errorf("failed to list peer pods: %v", err)
 		return err
 	}
 
-	var pingErr error
-	for _, pod := range pods.Items {
+	// a later success must not hide an earlier failure from the check
+	var errs []error
+	for _, pod := range pods {
 		for _, podIP := range pod.Status.PodIPs {
 			if util.ContainsString(config.PodProtocols, util.CheckProtocol(podIP.IP)) {
-				pingErr = pingPod(config, podIP.IP, pod.Name, pod.Status.HostIP, pod.Spec.NodeName)
+				if err = pingPod(ctx, config, podIP.IP, pod.Namespace, pod.Name, pod.Status.HostIP, pod.Spec.NodeName); err != nil {
+					errs = append(errs, fmt.Errorf("pod %s/%s %s: %v", pod.Namespace, pod.Name, podIP.IP, err))
+				}
 			}
 		}
 	}
 
-	return pingErr
+	return utilerrors.NewAggregate(errs)
 }
 
-func pingPod( config *Configuration, podIP, podName, nodeIP, nodeName string) error {
+func pingPod(ctx context.Context, config *Configuration, podIP, podNamespace, podName, nodeIP, nodeName string) error {
 	var pingErr error
-	pinger, err := goping.NewPinger(podIP)
+	result := ProbeResult{Check: CheckPod, Name: podName, Namespace: podNamespace, NodeName: nodeName, NodeIP: nodeIP, Address: podIP, Started: time.Now()}
+	profile := config.probeProfile(ProfilePod)
+	sent, recv, avgRtt, err := icmpProbe(ctx, podIP, profile)
 	if err != nil {
-		klog.Errorf("failed to init pinger, %v", err)
-		pingErr = err
-		return pingErr
-	}
-	pinger.SetPrivileged(true)
-	pinger.Timeout = 1 * time.Second
-	pinger.Debug = true
-	pinger.Count = 3
-	pinger.Interval = 100 * time.Millisecond
-	if err = pinger.Run(); err != nil {
 		klog.Errorf("failed to run pinger for destination %s: %v", podIP, err)
+		result.Error = err.Error()
+		config.recordResult(result)
 		pingErr = err
 		return pingErr
 	}
 
-	stats := pinger.Statistics()
-	klog.Infof("ping pod: %s %s, count: %d, loss count %d, average rtt %.2fms",
-		podName, podIP, pinger.Count, int(math.Abs(float64(stats.PacketsSent-stats.PacketsRecv))), float64(stats.AvgRtt)/float64(time.Millisecond))
-	if int(math.Abs(float64(stats.PacketsSent-stats.PacketsRecv))) != 0 {
+	lost := int(math.Abs(float64(sent - recv)))
+	if lost != 0 {
 		pingErr = fmt.Errorf("ping failed")
 	}
-	SetPodPingMetrics(
-		config.NodeName,
-		config.HostIP,
-		config.PodName,
-		nodeName,
-		nodeIP,
-		podIP,
-		float64(stats.AvgRtt)/float64(time.Millisecond),
-		int(math.Abs(float64(stats.PacketsSent-stats.PacketsRecv))),
-		int(float64(stats.PacketsSent)))
+	result.Sent, result.Lost, result.AvgRTT = sent, lost, avgRtt
+	result.Healthy = pingErr == nil
+	config.recordResult(result)
+	probeDSCP(ctx, config, result, profile, config.dscpClasses())
 	return pingErr
 }
 
-func pingIPs(config *Configuration) error {
+func pingIPs(ctx context.Context, config *Configuration) error {
 	klog.Infof("start to check ip connectivity")
-	ipList, err := config.NetworkClient.MecV1().IPs().List(context.TODO(), metaV1.ListOptions{})
+	ips, err := config.IPLister.List(labels.Everything())
 	if err != nil {
 		klog.Errorf("failed to list peer ips: %v", err)
 		return err
 	}
 
-	var pingErr error
-	for _, ip := range ipList.Items {
-		if util.ContainsString(config.PodProtocols, util.CheckProtocol(ip.Spec.V4IPAddress)) && strings.Compare(config.ExternalSubnet,ip.Spec.Subnet)==0{
-			pingErr = pingIP(config, ip.Spec.V4IPAddress)
+	var errs []error
+	subnet := config.externalSubnet()
+	for _, ip := range ips {
+		if util.ContainsString(config.PodProtocols, util.CheckProtocol(ip.Spec.V4IPAddress)) && strings.Compare(subnet,ip.Spec.Subnet)==0{
+			if err = pingIP(ctx, config, ip.Name, ip.Spec.V4IPAddress); err != nil {
+				errs = append(errs, fmt.Errorf("ip %s %s: %v", ip.Name, ip.Spec.V4IPAddress, err))
+			}
 		}
 	}
 
-	return pingErr
+	return utilerrors.NewAggregate(errs)
 }
 
-func pingIP(config *Configuration, IP string) error {
-	var pingErr error
-	pinger, err := goping.NewPinger(IP)
+func pingExternal(ctx context.Context, config *Configuration) error {
+	address := config.externalAddress()
+	klog.Infof("start to check ping external to %s", address)
+	result := ProbeResult{Check: CheckExternal, Name: address, Address: address, Started: time.Now()}
+	profile := config.probeProfile(ProfileExternal)
+	sent, recv, avgRtt, err := icmpProbe(ctx, address, profile)
 	if err != nil {
-		klog.Errorf("failed to init pinger, %v", err)
-		pingErr = err
-		return pingErr
+		klog.Errorf("failed to run pinger for external %s: %v", address, err)
+		result.Error = err.Error()
+		config.recordResult(result)
+		return err
 	}
-	pinger.SetPrivileged(true)
-	pinger.Timeout = 1 * time.Second
-	pinger.Debug = true
-	pinger.Count = 3
-	pinger.Interval = 100 * time.Millisecond
-	if err = pinger.Run(); err != nil {
-		klog.Errorf("failed to run pinger for destination %s: %v", podIP, err)
+
+	result.Sent, result.Lost, result.AvgRTT = sent, sent-recv, avgRtt
+	result.Healthy = sent != 0 && result.Lost == 0
+	config.recordResult(result)
+	probeDSCP(ctx, config, result, profile, config.dscpClasses())
+	if !result.Healthy {
+		return fmt.Errorf("ping failed")
+	}
+	return nil
+}
+
+func pingIP(ctx context.Context, config *Configuration, name, IP string) error {
+	var pingErr error
+	result := ProbeResult{Check: CheckIP, Name: name, Address: IP, Started: time.Now()}
+	profile := config.probeProfile(ProfileIP)
+	sent, recv, avgRtt, err := icmpProbe(ctx, IP, profile)
+	if err != nil {
+		klog.Errorf("failed to run pinger for destination %s: %v", IP, err)
+		result.Error = err.Error()
+		config.recordResult(result)
 		pingErr = err
 		return pingErr
 	}
 
-	stats := pinger.Statistics()
-	klog.Infof("ping IP: %s, count: %d, loss count %d, average rtt %.2fms",
-		 IP, pinger.Count, int(math.Abs(float64(stats.PacketsSent-stats.PacketsRecv))), float64(stats.AvgRtt)/float64(time.Millisecond))
-	if int(math.Abs(float64(stats.PacketsSent-stats.PacketsRecv))) != 0 {
+	lost := int(math.Abs(float64(sent - recv)))
+	if lost != 0 {
 		pingErr = fmt.Errorf("ping failed")
 	}
-	SetIPPingMetrics(
-		config.NodeName,
-		config.HostIP,
-		config.PodName,
-		IP,
-		float64(stats.AvgRtt)/float64(time.Millisecond),
-		int(math.Abs(float64(stats.PacketsSent-stats.PacketsRecv))),
-		int(float64(stats.PacketsSent)))
+	result.Sent, result.Lost, result.AvgRTT = sent, lost, avgRtt
+	result.Healthy = pingErr == nil
+	config.recordResult(result)
+	probeDSCP(ctx, config, result, profile, config.dscpClasses())
 	return pingErr
 }
 
-func pingNodes(config *Configuration) error {
+func pingNodes(ctx context.Context, config *Configuration) error {
 	klog.Infof("start to check node connectivity")
-	nodes, err := config.KubeClient.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	nodes, err := config.nodeLister.List(labels.Everything())
 	if err != nil {
 		klog.Errorf("failed to list nodes, %v", err)
 		return err
 	}
 
-	var pingErr error
-	for _, no := range nodes.Items {
+	var errs []error
+	for _, no := range nodes {
 		for _, addr := range no.Status.Addresses {
 			if addr.Type == v1.NodeInternalIP && util.ContainsString(config.PodProtocols, util.CheckProtocol(addr.Address)) {
 				func(nodeIP, nodeName string) {
-
-					pinger, err := goping.NewPinger(nodeIP)
+					result := ProbeResult{Check: CheckNode, Name: nodeName, NodeName: nodeName, Address: nodeIP, Started: time.Now()}
+					profile := config.probeProfile(ProfileNode)
+					sent, recv, avgRtt, err := icmpProbe(ctx, nodeIP, profile)
 					if err != nil {
-						klog.Errorf("failed to init pinger, %v", err)
-						pingErr = err
-						return
-					}
-					pinger.SetPrivileged(true)
-					pinger.Timeout = 30 * time.Second
-					pinger.Count = 3
-					pinger.Interval = 100 * time.Millisecond
-					pinger.Debug = true
-					if err = pinger.Run(); err != nil {
 						klog.Errorf("failed to run pinger for destination %s: %v", nodeIP, err)
-						pingErr = err
+						result.Error = err.Error()
+						config.recordResult(result)
+						errs = append(errs, fmt.Errorf("node %s %s: %v", nodeName, nodeIP, err))
 						return
 					}
 
-					stats := pinger.Statistics()
-					klog.Infof("ping node: %s %s, count: %d, loss count %d, average rtt %.2fms",
-						nodeName, nodeIP, pinger.Count, int(math.Abs(float64(stats.PacketsSent-stats.PacketsRecv))), float64(stats.AvgRtt)/float64(time.Millisecond))
-					if int(math.Abs(float64(stats.PacketsSent-stats.PacketsRecv))) != 0 {
-						pingErr = fmt.Errorf("ping failed")
+					lost := int(math.Abs(float64(sent - recv)))
+					if lost != 0 {
+						errs = append(errs, fmt.Errorf("node %s %s: ping failed", nodeName, nodeIP))
 					}
-					SetNodePingMetrics(
-						config.NodeName,
-						config.HostIP,
-						config.PodName,
-						no.Name, addr.Address,
-						float64(stats.AvgRtt)/float64(time.Millisecond),
-						int(math.Abs(float64(stats.PacketsSent-stats.PacketsRecv))),
-						int(float64(stats.PacketsSent)))
+					result.Sent, result.Lost, result.AvgRTT = sent, lost, avgRtt
+					result.Healthy = result.Lost == 0
+					config.recordResult(result)
+					probeDSCP(ctx, config, result, profile, config.dscpClasses())
 				}(addr.Address, no.Name)
 			}
 		}
 	}
-	return pingErr
+	return utilerrors.NewAggregate(errs)
 }
 
-func internalNslookup(config *Configuration) error {
+func internalNslookup(ctx context.Context, config *Configuration) error {
 	klog.Infof("start to check dns connectivity")
 	t1 := time.Now()
-	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 	var r net.Resolver
-	addrs, err := r.LookupHost(ctx, config.InternalDNS)
+	name := config.internalDNS()
+	addrs, err := r.LookupHost(ctx, name)
 	elapsed := time.Since(t1)
 	if err != nil {
-		klog.Errorf("failed to resolve dns %s, %v", config.InternalDNS, err)
-		SetInternalDNSUnhealthyMetrics(config.NodeName)
+		klog.Errorf("failed to resolve dns %s, %v", name, err)
+		config.recordResult(ProbeResult{Check: CheckDNS, Name: name, Started: t1, Error: err.Error()})
 		return err
 	}
-	SetInternalDNSHealthyMetrics(config.NodeName, float64(elapsed)/float64(time.Millisecond))
-	klog.Infof("resolve dns %s to %v in %.2fms", config.InternalDNS, addrs, float64(elapsed)/float64(time.Millisecond))
+	klog.V(3).Infof("dns %s resolves to %v", name, addrs)
+	config.recordResult(ProbeResult{Check: CheckDNS, Name: name, Started: t1, AvgRTT: elapsed, Healthy: true})
 	return nil
 }
 
-func SetPodPingMetrics(srcNodeName, srcNodeIP, srcPodIP, targetNodeName, targetNodeIP, targetPodIP string, latency float64, lost, total int) {
-	podPingLatencyHistogram.WithLabelValues(
-		srcNodeName,
-		srcNodeIP,
-		srcPodIP,
-		targetNodeName,
-		targetNodeIP,
-		targetPodIP,
-	).Observe(latency)
-	podPingLostCounter.WithLabelValues(
-		srcNodeName,
-		srcNodeIP,
-		srcPodIP,
-		targetNodeName,
-		targetNodeIP,
-		targetPodIP,
-	).Add(float64(lost))
-	podPingTotalCounter.WithLabelValues(
-		srcNodeName,
-		srcNodeIP,
-		srcPodIP,
-		targetNodeName,
-		targetNodeIP,
-		targetPodIP,
-	).Add(float64(total))
-}
-
-func SetIPPingMetrics(srcNodeName, srcNodeIP, srcPodIP,  targetIP string, latency float64, lost, total int) {
-	IpPingLatencyHistogram.WithLabelValues(
-		srcNodeName,
-		srcNodeIP,
-		srcPodIP,
-		targetIP,
-	).Observe(latency)
-	IpPingLostCounter.WithLabelValues(
-		srcNodeName,
-		srcNodeIP,
-		srcPodIP,
-		targetIP,
-	).Add(float64(lost))
-	IpPingTotalCounter.WithLabelValues(
-		srcNodeName,
-		srcNodeIP,
-		srcPodIP,
-		targetIP,
-	).Add(float64(total))
-}
-
-func SetNodePingMetrics(srcNodeName, srcNodeIP, srcPodIP, targetNodeName, targetNodeIP string, latency float64, lost, total int) {
-	nodePingLatencyHistogram.WithLabelValues(
-		srcNodeName,
-		srcNodeIP,
-		srcPodIP,
-		targetNodeName,
-		targetNodeIP,
-	).Observe(latency)
-	nodePingLostCounter.WithLabelValues(
-		srcNodeName,
-		srcNodeIP,
-		srcPodIP,
-		targetNodeName,
-		targetNodeIP,
-	).Add(float64(lost))
-	nodePingTotalCounter.WithLabelValues(
-		srcNodeName,
-		srcNodeIP,
-		srcPodIP,
-		targetNodeName,
-		targetNodeIP,
-	).Add(float64(total))
+func externalNslookup(ctx context.Context, config *Configuration) error {
+	klog.Infof("start to check external dns connectivity")
+	t1 := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	var r net.Resolver
+	name := config.externalDNS()
+	addrs, err := r.LookupHost(ctx, name)
+	elapsed := time.Since(t1)
+	if err != nil {
+		klog.Errorf("failed to resolve external dns %s, %v", name, err)
+		config.recordResult(ProbeResult{Check: CheckExtDNS, Name: name, Started: t1, Error: err.Error()})
+		return err
+	}
+	klog.V(3).Infof("external dns %s resolves to %v", name, addrs)
+	config.recordResult(ProbeResult{Check: CheckExtDNS, Name: name, Started: t1, AvgRTT: elapsed, Healthy: true})
+	return nil
 }
@@ -0,0 +1,210 @@
+package pinger
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	networkv1 "pkg/apis/network/v1"
+)
+
+const ConditionReady = "Ready"
+
+// checkConditions maps every check to the condition type summarising it.
+var checkConditions = map[string]string{
+	CheckAPIServer: "APIServerReachable",
+	CheckDNS:       "DNSResolvable",
+	CheckExtDNS:    "ExternalDNSResolvable",
+	CheckPod:       "PodsReachable",
+	CheckNode:      "NodesReachable",
+	CheckIP:        "IPsReachable",
+	CheckTarget:    "TargetsReachable",
+	CheckExternal:  "ExternalReachable",
+}
+
+// updatePingResult publishes the latest results of this node to the PingResult named after it.
+func updatePingResult(ctx context.Context, config *Configuration) error {
+	client := config.NetworkClient.MecV1().PingResults()
+	result, err := client.Get(ctx, config.NodeName, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			klog.Errorf("failed to get ping result %s: %v", config.NodeName, err)
+			return err
+		}
+		result, err = client.Create(ctx, &networkv1.PingResult{
+			ObjectMeta: metav1.ObjectMeta{Name: config.NodeName},
+			Spec: networkv1.PingResultSpec{
+				NodeName: config.NodeName,
+				NodeIP:   config.HostIP,
+				PodName:  config.PodName,
+				Topology: config.topology.node(config.NodeName),
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			klog.Errorf("failed to create ping result %s: %v", config.NodeName, err)
+			return err
+		}
+	}
+
+	topology := config.topology.node(config.NodeName)
+	if result.Spec.NodeIP != config.HostIP || result.Spec.PodName != config.PodName || !equality.Semantic.DeepEqual(result.Spec.Topology, topology) {
+		result = result.DeepCopy()
+		result.Spec.NodeIP = config.HostIP
+		result.Spec.PodName = config.PodName
+		result.Spec.Topology = topology
+		if result, err = client.Update(ctx, result, metav1.UpdateOptions{}); err != nil {
+			klog.Errorf("failed to update ping result %s: %v", config.NodeName, err)
+			return err
+		}
+	}
+
+	previous := result.Status
+	result = result.DeepCopy()
+	buildPingResultStatus(&result.Status, config.results.snapshot(config.resultTTL()), result.Generation, config.PingResultMaxTargets, config.PingResultMaxTraces)
+	if pingResultStatusEqual(&previous, &result.Status) && time.Since(previous.LastUpdateTime.Time) < config.PingResultHeartbeat {
+		return nil
+	}
+	result.Status.LastUpdateTime = metav1.Now()
+	if _, err = client.UpdateStatus(ctx, result, metav1.UpdateOptions{}); err != nil {
+		klog.Errorf("failed to update status of ping result %s: %v", config.NodeName, err)
+		return err
+	}
+	return nil
+}
+
+// buildPingResultStatus summarises the states, at most maxTargets of them are listed with at most
+// maxTraces traces so the object stays far below the etcd size limit on large clusters.
+func buildPingResultStatus(status *networkv1.PingResultStatus, states []TargetState, generation int64, maxTargets, maxTraces int) {
+	sort.SliceStable(states, func(i, j int) bool {
+		if states[i].Healthy != states[j].Healthy {
+			return !states[i].Healthy
+		}
+		if states[i].Check != states[j].Check {
+			return states[i].Check < states[j].Check
+		}
+		return targetDisplayName(states[i].ProbeResult) < targetDisplayName(states[j].ProbeResult)
+	})
+
+	status.Reachable, status.Unreachable, status.OmittedTargets = 0, 0, 0
+	status.Targets = make([]networkv1.TargetResult, 0, min(len(states), maxTargets))
+	failed := make(map[string][]string)
+	seen := make(map[string]bool)
+	traces := 0
+	for _, state := range states {
+		seen[state.Check] = true
+		if state.Healthy {
+			status.Reachable++
+		} else {
+			status.Unreachable++
+			failed[state.Check] = append(failed[state.Check], targetDisplayName(state.ProbeResult))
+		}
+		if len(status.Targets) == maxTargets {
+			status.OmittedTargets++
+			continue
+		}
+		target := networkv1.TargetResult{
+			Check:              state.Check,
+			Name:               state.Name,
+			Namespace:          state.Namespace,
+			NodeName:           state.NodeName,
+			Address:            state.Address,
+			Topology:           state.Topology,
+			Reachable:          state.Healthy,
+			Sent:               int32(state.Sent),
+			Lost:               int32(state.Lost),
+			AvgRTT:             metav1.Duration{Duration: state.AvgRTT},
+			Error:              state.Error,
+			LastProbeTime:      metav1.NewTime(state.Timestamp),
+			LastTransitionTime: metav1.NewTime(state.LastTransitionTime),
+		}
+		if state.Trace != nil && traces < maxTraces {
+			target.Trace = traceResult(state.Trace)
+			traces++
+		}
+		if state.PathMTU != nil {
+			target.PathMTU, target.LocalMTU = int32(state.PathMTU.PathMTU), int32(state.PathMTU.LocalMTU)
+		}
+		status.Targets = append(status.Targets, target)
+	}
+
+	ready := metav1.Condition{Type: ConditionReady, Status: metav1.ConditionTrue, Reason: "AllReachable", ObservedGeneration: generation}
+	checks := make([]string, 0, len(checkConditions))
+	for check := range checkConditions {
+		checks = append(checks, check)
+	}
+	// a stable order keeps the status from changing between updates without a reason
+	sort.Strings(checks)
+	for _, check := range checks {
+		conditionType := checkConditions[check]
+		if !seen[check] {
+			meta.RemoveStatusCondition(&status.Conditions, conditionType)
+			continue
+		}
+		condition := metav1.Condition{Type: conditionType, Status: metav1.ConditionTrue, Reason: "Reachable", ObservedGeneration: generation}
+		if names := failed[check]; len(names) != 0 {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "Unreachable"
+			condition.Message = joinLimited(names, 10)
+			ready.Status = metav1.ConditionFalse
+			ready.Reason = "TargetsUnreachable"
+		}
+		meta.SetStatusCondition(&status.Conditions, condition)
+	}
+	if ready.Status == metav1.ConditionFalse {
+		ready.Message = fmt.Sprintf("%d targets unreachable", status.Unreachable)
+	}
+	meta.SetStatusCondition(&status.Conditions, ready)
+}
+
+// pingResultStatusEqual compares the statuses without the measurements that change every
+// cycle, the probe times, packet counts, rtts and trace hops, so only a change of a target
+// state or of the conditions is written.
+func pingResultStatusEqual(a, b *networkv1.PingResultStatus) bool {
+	if a.Reachable != b.Reachable || a.Unreachable != b.Unreachable || a.OmittedTargets != b.OmittedTargets ||
+		len(a.Targets) != len(b.Targets) || !equality.Semantic.DeepEqual(a.Conditions, b.Conditions) {
+		return false
+	}
+	for i := range a.Targets {
+		if !equality.Semantic.DeepEqual(significantTarget(a.Targets[i]), significantTarget(b.Targets[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+func significantTarget(t networkv1.TargetResult) networkv1.TargetResult {
+	t.Sent, t.Lost = 0, 0
+	t.AvgRTT = metav1.Duration{}
+	t.LastProbeTime = metav1.Time{}
+	if t.Trace != nil {
+		t.Trace = &networkv1.TraceResult{}
+	}
+	return t
+}
+
+func targetDisplayName(r ProbeResult) string {
+	name := r.Name
+	if r.Namespace != "" {
+		name = r.Namespace + "/" + name
+	}
+	if r.Address != "" && r.Address != r.Name {
+		name += "(" + r.Address + ")"
+	}
+	return name
+}
+
+// joinLimited keeps condition messages short on large clusters.
+func joinLimited(names []string, limit int) string {
+	if len(names) <= limit {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:limit], ", "), len(names)-limit)
+}
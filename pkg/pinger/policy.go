@@ -0,0 +1,121 @@
+package pinger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// PolicyCritical checks fail the job, PolicyWarning checks are only logged and reported,
+	// PolicyIgnore checks still run but never affect the result.
+	PolicyCritical = "critical"
+	PolicyWarning  = "warning"
+	PolicyIgnore   = "ignore"
+
+	ExitCodeModeSingle  = "single"
+	ExitCodeModeBitmask = "bitmask"
+)
+
+// checkExitBits is the bit every critical check sets in the exit code of the bitmask mode. The exit
+// status of a process keeps only 8 bits, so the external dns check shares the bit of dns and the
+// external address check the bit of ip.
+var checkExitBits = map[string]int{
+	CheckAPIServer: 1 << 0,
+	CheckPod:       1 << 1,
+	CheckNode:      1 << 2,
+	CheckDNS:       1 << 3,
+	CheckIP:        1 << 4,
+	CheckTarget:    1 << 5,
+	CheckIPAudit:   1 << 6,
+	CheckSubnet:    1 << 7,
+	CheckExtDNS:    1 << 3,
+	CheckExternal:  1 << 4,
+}
+
+func validateCheckPolicies(policies map[string]string) error {
+	for check, policy := range policies {
+		if _, ok := checkExitBits[check]; !ok {
+			return fmt.Errorf("unknown check %q in check policy, valid checks are %s", check, strings.Join(checkNames(), ", "))
+		}
+		switch policy {
+		case PolicyCritical, PolicyWarning, PolicyIgnore:
+		default:
+			return fmt.Errorf("invalid policy %q of check %s, expect %s, %s or %s", policy, check, PolicyCritical, PolicyWarning, PolicyIgnore)
+		}
+	}
+	return nil
+}
+
+func validateCheckThresholds(name string, thresholds map[string]int, max int) error {
+	for check, threshold := range thresholds {
+		if _, ok := checkExitBits[check]; !ok {
+			return fmt.Errorf("unknown check %q in %s, valid checks are %s", check, name, strings.Join(checkNames(), ", "))
+		}
+		if check == CheckTarget {
+			return fmt.Errorf("ping targets take the %s from the thresholds of their spec", name)
+		}
+		if threshold < 0 || threshold > max {
+			return fmt.Errorf("invalid %s %d of check %s, it must be between 0 and %d", name, threshold, check, max)
+		}
+	}
+	return nil
+}
+
+func checkNames() []string {
+	names := make([]string, 0, len(checkExitBits))
+	for check := range checkExitBits {
+		names = append(names, check)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkPolicy returns the policy of the check, checks are critical unless configured otherwise.
+func (config *Configuration) checkPolicy(check string) string {
+	config.mu.RLock()
+	defer config.mu.RUnlock()
+	if policy, ok := config.CheckPolicies[check]; ok {
+		return policy
+	}
+	return PolicyCritical
+}
+
+// resultPassed judges a probe result by the health recorded with it and the loss and rtt thresholds
+// of its check. A loss threshold replaces the any loss rule of the recorded health, ping targets are
+// only judged by the thresholds of their spec, which their recorded health already applies.
+func (config *Configuration) resultPassed(r ProbeResult) bool {
+	if r.Error != "" {
+		return false
+	}
+	if r.Check == CheckTarget {
+		return r.Healthy
+	}
+	config.mu.RLock()
+	maxLoss, ok := config.MaxLossPercent[r.Check]
+	maxRTT := config.MaxRTTMilliseconds[r.Check]
+	config.mu.RUnlock()
+	if maxRTT > 0 && r.AvgRTT > time.Duration(maxRTT)*time.Millisecond {
+		return false
+	}
+	if ok && r.Sent != 0 {
+		return r.Lost*100 <= maxLoss*r.Sent
+	}
+	return r.Healthy
+}
+
+// exitCode is the process exit code of a job: --exit-code when a critical check failed in the single mode,
+// or the bits of all failed critical checks in the bitmask mode.
+func (config *Configuration) exitCode(report *Report) int {
+	code := 0
+	for _, check := range report.Checks {
+		if !check.Passed && check.Policy == PolicyCritical {
+			code |= checkExitBits[check.Name]
+		}
+	}
+	if config.ExitCodeMode == ExitCodeModeBitmask || code == 0 {
+		return code
+	}
+	return config.ExitCode
+}
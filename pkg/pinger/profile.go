@@ -0,0 +1,222 @@
+package pinger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	goping "github.com/prometheus-community/pro-bing"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+
+	"github.com/wenwenxiong/network-pinger/pkg/echo"
+)
+
+const (
+	ProfilePod      = "pod"
+	ProfileNode     = "node"
+	ProfileIP       = "ip"
+	ProfileExternal = "external"
+
+	// the ping library needs room for its timestamp and tracker in every payload
+	minProbeSize = 24
+	maxProbeSize = 65507
+)
+
+// ProbeProfile is how the targets of a class are pinged. Timeout bounds the whole probe,
+// not a single echo request, and TOS is the tos byte (dscp << 2) of ipv4 probes or the
+// traffic class of ipv6 probes.
+type ProbeProfile struct {
+	Count    int             `json:"count,omitempty"`
+	Interval metav1.Duration `json:"interval,omitempty"`
+	Timeout  metav1.Duration `json:"timeout,omitempty"`
+	Size     int             `json:"size,omitempty"`
+	TTL      int             `json:"ttl,omitempty"`
+	TOS      int             `json:"tos,omitempty"`
+}
+
+// defaultProbeProfiles keeps the values the probes used before they could be configured,
+// node probes have always waited longer than the others.
+func defaultProbeProfiles() map[string]ProbeProfile {
+	profile := ProbeProfile{
+		Count:    3,
+		Interval: metav1.Duration{Duration: 100 * time.Millisecond},
+		Timeout:  metav1.Duration{Duration: time.Second},
+		Size:     minProbeSize,
+		TTL:      64,
+	}
+	node := profile
+	node.Timeout = metav1.Duration{Duration: 30 * time.Second}
+	return map[string]ProbeProfile{
+		ProfilePod:      profile,
+		ProfileNode:     node,
+		ProfileIP:       profile,
+		ProfileExternal: profile,
+	}
+}
+
+// merge overrides the fields set in the other profile.
+func (p ProbeProfile) merge(other ProbeProfile) ProbeProfile {
+	if other.Count != 0 {
+		p.Count = other.Count
+	}
+	if other.Interval.Duration != 0 {
+		p.Interval = other.Interval
+	}
+	if other.Timeout.Duration != 0 {
+		p.Timeout = other.Timeout
+	}
+	if other.Size != 0 {
+		p.Size = other.Size
+	}
+	if other.TTL != 0 {
+		p.TTL = other.TTL
+	}
+	if other.TOS != 0 {
+		p.TOS = other.TOS
+	}
+	return p
+}
+
+func (p ProbeProfile) validate() error {
+	switch {
+	case p.Count <= 0:
+		return fmt.Errorf("count must be positive")
+	case p.Interval.Duration <= 0:
+		return fmt.Errorf("interval must be positive")
+	case p.Timeout.Duration <= 0:
+		return fmt.Errorf("timeout must be positive")
+	case p.Size < minProbeSize || p.Size > maxProbeSize:
+		return fmt.Errorf("size must be between %d and %d", minProbeSize, maxProbeSize)
+	case p.TTL <= 0 || p.TTL > 255:
+		return fmt.Errorf("ttl must be between 1 and 255")
+	case p.TOS < 0 || p.TOS > 255:
+		return fmt.Errorf("tos must be between 0 and 255")
+	}
+	return nil
+}
+
+// parseProbeProfile parses a --probe-profile flag, class:key=value,key=value.
+func parseProbeProfile(value string) (string, ProbeProfile, error) {
+	var profile ProbeProfile
+	class, settings, ok := strings.Cut(value, ":")
+	if !ok {
+		return "", profile, fmt.Errorf("probe profile %q is not class:key=value,...", value)
+	}
+	for _, setting := range strings.Split(settings, ",") {
+		key, v, ok := strings.Cut(strings.TrimSpace(setting), "=")
+		if !ok {
+			return "", profile, fmt.Errorf("probe profile setting %q is not key=value", setting)
+		}
+		var err error
+		switch key {
+		case "count":
+			profile.Count, err = strconv.Atoi(v)
+		case "interval":
+			profile.Interval.Duration, err = time.ParseDuration(v)
+		case "timeout":
+			profile.Timeout.Duration, err = time.ParseDuration(v)
+		case "size":
+			profile.Size, err = strconv.Atoi(v)
+		case "ttl":
+			profile.TTL, err = strconv.Atoi(v)
+		case "tos":
+			var tos uint64
+			tos, err = strconv.ParseUint(v, 0, 8)
+			profile.TOS = int(tos)
+		default:
+			err = fmt.Errorf("unknown setting")
+		}
+		if err != nil {
+			return "", profile, fmt.Errorf("invalid probe profile setting %q: %v", setting, err)
+		}
+	}
+	return class, profile, nil
+}
+
+// loadProbeProfiles overrides the default profiles with the yaml or json file, then with the
+// profiles of the configuration file and last with the flags.
+func loadProbeProfiles(file string, fromConfig map[string]ProbeProfile, flags []string) (map[string]ProbeProfile, error) {
+	profiles := defaultProbeProfiles()
+	overrides := make(map[string][]ProbeProfile)
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read probe profiles: %v", err)
+		}
+		fromFile := make(map[string]ProbeProfile)
+		if err = yaml.UnmarshalStrict(data, &fromFile); err != nil {
+			return nil, fmt.Errorf("failed to parse probe profiles %s: %v", file, err)
+		}
+		for class, profile := range fromFile {
+			overrides[class] = append(overrides[class], profile)
+		}
+	}
+	for class, profile := range fromConfig {
+		overrides[class] = append(overrides[class], profile)
+	}
+	for _, flag := range flags {
+		class, profile, err := parseProbeProfile(flag)
+		if err != nil {
+			return nil, err
+		}
+		overrides[class] = append(overrides[class], profile)
+	}
+
+	for class, list := range overrides {
+		profile, ok := profiles[class]
+		if !ok {
+			return nil, fmt.Errorf("unknown probe profile class %q, valid classes are %s, %s, %s and %s",
+				class, ProfilePod, ProfileNode, ProfileIP, ProfileExternal)
+		}
+		for _, override := range list {
+			profile = profile.merge(override)
+		}
+		profiles[class] = profile
+	}
+	for class, profile := range profiles {
+		if err := profile.validate(); err != nil {
+			return nil, fmt.Errorf("invalid %s probe profile: %v", class, err)
+		}
+	}
+	return profiles, nil
+}
+
+// icmpProbe pings the address with the profile and returns the sent and received counts and the average rtt.
+func icmpProbe(ctx context.Context, address string, profile ProbeProfile) (int, int, time.Duration, error) {
+	if profile.TOS != 0 {
+		stats, err := echo.Run(ctx, address, echo.Options{
+			Count:    profile.Count,
+			Interval: profile.Interval.Duration,
+			Timeout:  profile.Timeout.Duration,
+			Size:     profile.Size,
+			TTL:      profile.TTL,
+			TOS:      profile.TOS,
+		})
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return stats.Sent, stats.Recv, stats.AvgRTT, nil
+	}
+
+	pinger, err := goping.NewPinger(address)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	pinger.SetPrivileged(true)
+	pinger.Debug = klog.V(4).Enabled()
+	pinger.Count = profile.Count
+	pinger.Interval = profile.Interval.Duration
+	pinger.Timeout = profile.Timeout.Duration
+	pinger.Size = profile.Size
+	pinger.TTL = profile.TTL
+	if err = pinger.RunWithContext(ctx); err != nil {
+		return 0, 0, 0, err
+	}
+	stats := pinger.Statistics()
+	return stats.PacketsSent, stats.PacketsRecv, stats.AvgRtt, nil
+}
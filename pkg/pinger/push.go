@@ -0,0 +1,149 @@
+package pinger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/prompb"
+	"k8s.io/klog/v2"
+)
+
+// pushMetrics exports the metrics of a job mode run, which never serves /metrics, and the last
+// metrics of server mode when it shuts down to the configured pushgateway, remote write endpoint
+// and otlp collector.
+func (config *Configuration) pushMetrics(ctx context.Context) error {
+	var pushErr error
+	if config.PushGateway != "" {
+		err := push.New(config.PushGateway, config.PushJob).
+			Gatherer(config.gatherer).
+			Grouping("node", config.NodeName).
+			PushContext(ctx)
+		if err != nil {
+			klog.Errorf("failed to push metrics to pushgateway %s: %v", config.PushGateway, err)
+			pushErr = err
+		} else {
+			klog.Infof("pushed metrics to pushgateway %s", config.PushGateway)
+		}
+	}
+	if config.RemoteWriteURL != "" {
+		if err := config.remoteWrite(ctx); err != nil {
+			klog.Errorf("failed to send metrics to remote write endpoint %s: %v", config.RemoteWriteURL, err)
+			pushErr = err
+		} else {
+			klog.Infof("sent metrics to remote write endpoint %s", config.RemoteWriteURL)
+		}
+	}
+	if config.otel != nil {
+		if err := config.otel.exportMetrics(ctx); err != nil {
+			pushErr = err
+		} else {
+			klog.Infof("exported metrics to otlp collector %s", config.OTLPEndpoint)
+		}
+	}
+	return pushErr
+}
+
+func (config *Configuration) remoteWrite(ctx context.Context) error {
+	families, err := config.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+	body, err := buildWriteRequest(families, map[string]string{"job": config.PushJob, "node": config.NodeName}, time.Now()).Marshal()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.RemoteWriteURL, bytes.NewReader(snappy.Encode(nil, body)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "network-pinger")
+	client := &http.Client{Timeout: config.RemoteWriteTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("remote write answered %s", resp.Status)
+	}
+	return nil
+}
+
+// buildWriteRequest flattens the metric families into the time series of a remote write
+// request the way a scrape would, histograms and summaries become several series.
+func buildWriteRequest(families []*dto.MetricFamily, extraLabels map[string]string, now time.Time) *prompb.WriteRequest {
+	timestamp := now.UnixMilli()
+	req := &prompb.WriteRequest{}
+	appendSeries := func(name string, labels []prompb.Label, value float64) {
+		series := make([]prompb.Label, 0, len(labels)+len(extraLabels)+1)
+		series = append(series, prompb.Label{Name: "__name__", Value: name})
+		for k, v := range extraLabels {
+			series = append(series, prompb.Label{Name: k, Value: v})
+		}
+		// labels of the metric win over the extra ones
+		for _, l := range labels {
+			replaced := false
+			for i := range series {
+				if series[i].Name == l.Name {
+					series[i].Value, replaced = l.Value, true
+				}
+			}
+			if !replaced {
+				series = append(series, l)
+			}
+		}
+		sort.Slice(series, func(i, j int) bool { return series[i].Name < series[j].Name })
+		req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+			Labels:  series,
+			Samples: []prompb.Sample{{Value: value, Timestamp: timestamp}},
+		})
+	}
+
+	for _, family := range families {
+		name := family.GetName()
+		for _, m := range family.GetMetric() {
+			labels := make([]prompb.Label, 0, len(m.GetLabel())+1)
+			for _, l := range m.GetLabel() {
+				labels = append(labels, prompb.Label{Name: l.GetName(), Value: l.GetValue()})
+			}
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				appendSeries(name, labels, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				appendSeries(name, labels, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				appendSeries(name, labels, m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					le := strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64)
+					appendSeries(name+"_bucket", append(labels, prompb.Label{Name: "le", Value: le}), float64(b.GetCumulativeCount()))
+				}
+				appendSeries(name+"_bucket", append(labels, prompb.Label{Name: "le", Value: "+Inf"}), float64(h.GetSampleCount()))
+				appendSeries(name+"_sum", labels, h.GetSampleSum())
+				appendSeries(name+"_count", labels, float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					quantile := strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64)
+					appendSeries(name, append(labels, prompb.Label{Name: "quantile", Value: quantile}), q.GetValue())
+				}
+				appendSeries(name+"_sum", labels, s.GetSampleSum())
+				appendSeries(name+"_count", labels, float64(s.GetSampleCount()))
+			}
+		}
+	}
+	return req
+}
@@ -0,0 +1,292 @@
+package pinger
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	ReportFormatJSON     = "json"
+	ReportFormatJUnit    = "junit"
+	ReportFormatMarkdown = "markdown"
+)
+
+// Report is the outcome of one job mode cycle, the json report carries the duration in seconds.
+type Report struct {
+	NodeName        string        `json:"nodeName"`
+	PodName         string        `json:"podName"`
+	StartTime       time.Time     `json:"startTime"`
+	Duration        time.Duration `json:"-"`
+	DurationSeconds float64       `json:"durationSeconds"`
+	Passed          bool          `json:"passed"`
+	Checks          []CheckReport `json:"checks"`
+}
+
+// CheckReport is the outcome of one check, Error is set when the check failed before probing any target.
+type CheckReport struct {
+	Name    string         `json:"name"`
+	Policy  string         `json:"policy"`
+	Passed  bool           `json:"passed"`
+	Error   string         `json:"error,omitempty"`
+	Targets []TargetReport `json:"targets,omitempty"`
+}
+
+type TargetReport struct {
+	Name        string            `json:"name"`
+	Address     string            `json:"address,omitempty"`
+	Topology    map[string]string `json:"topology,omitempty"`
+	Passed      bool              `json:"passed"`
+	Sent        int               `json:"sent"`
+	Lost        int               `json:"lost"`
+	LossPercent float64           `json:"lossPercent"`
+	AvgRTTMs    float64           `json:"avgRttMs"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// reportChecks lists the checks of a cycle in the order they run.
+func reportChecks(config *Configuration) []string {
+	checks := []string{CheckAPIServer, CheckPod, CheckNode, CheckDNS}
+	if config.externalDNS() != "" {
+		checks = append(checks, CheckExtDNS)
+	}
+	checks = append(checks, CheckIP)
+	if config.externalAddress() != "" {
+		checks = append(checks, CheckExternal)
+	}
+	if config.EnablePingTargets {
+		checks = append(checks, CheckTarget)
+	}
+	if config.ipAuditEnabled() {
+		checks = append(checks, CheckIPAudit)
+	}
+	if config.EnableSubnetMetrics {
+		checks = append(checks, CheckSubnet)
+	}
+	return checks
+}
+
+func buildReport(config *Configuration, startTime time.Time, failures map[string]error) *Report {
+	duration := time.Since(startTime)
+	report := &Report{
+		NodeName:        config.NodeName,
+		PodName:         config.PodName,
+		StartTime:       startTime,
+		Duration:        duration,
+		DurationSeconds: duration.Seconds(),
+		Passed:          true,
+	}
+
+	targets := make(map[string][]TargetReport)
+	for _, state := range config.results.snapshot(config.resultTTL()) {
+		if state.Timestamp.Before(startTime) {
+			continue
+		}
+		target := TargetReport{
+			Name:     targetDisplayName(state.ProbeResult),
+			Address:  state.Address,
+			Topology: state.Topology,
+			Passed:   config.resultPassed(state.ProbeResult),
+			Sent:     state.Sent,
+			Lost:     state.Lost,
+			AvgRTTMs: float64(state.AvgRTT) / float64(time.Millisecond),
+			Error:    state.Error,
+		}
+		if state.Sent != 0 {
+			target.LossPercent = float64(state.Lost) * 100 / float64(state.Sent)
+		}
+		targets[state.Check] = append(targets[state.Check], target)
+	}
+
+	for _, name := range reportChecks(config) {
+		check := CheckReport{Name: name, Policy: config.checkPolicy(name), Passed: true, Targets: targets[name]}
+		// failed targets carry their own errors, the check error matters only when nothing was probed
+		if err := failures[name]; err != nil && len(check.Targets) == 0 {
+			check.Passed = false
+			check.Error = err.Error()
+		}
+		for _, target := range check.Targets {
+			if !target.Passed {
+				check.Passed = false
+			}
+		}
+		if !check.Passed {
+			switch check.Policy {
+			case PolicyCritical:
+				report.Passed = false
+			case PolicyWarning:
+				klog.Warningf("check %s failed, ignored by its %s policy", check.Name, check.Policy)
+			}
+		}
+		report.Checks = append(report.Checks, check)
+	}
+	return report
+}
+
+func writeReport(format, path string, report *Report) error {
+	var data []byte
+	var err error
+	switch format {
+	case ReportFormatJSON:
+		data, err = json.MarshalIndent(report, "", "  ")
+	case ReportFormatJUnit:
+		data, err = junitReport(report)
+	case ReportFormatMarkdown:
+		data = markdownReport(report)
+	default:
+		err = fmt.Errorf("unsupported report format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	if path == "-" {
+		_, err = fmt.Fprintln(os.Stdout, string(data))
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitFailure `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitReport maps every check to a test suite and every target to a test case,
+// a check failing without targets becomes a single failed case. Failures of checks that are not
+// critical are reported as skipped so they do not fail the pipeline.
+func junitReport(report *Report) ([]byte, error) {
+	suites := junitTestSuites{
+		Name: "network-pinger " + report.NodeName,
+		Time: fmt.Sprintf("%.3f", report.Duration.Seconds()),
+	}
+	for _, check := range report.Checks {
+		suite := junitTestSuite{Name: check.Name, Timestamp: report.StartTime.UTC().Format(time.RFC3339)}
+		if check.Error != "" {
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      check.Name,
+				ClassName: check.Name,
+				Time:      "0",
+				Failure:   &junitFailure{Message: check.Error, Text: check.Error},
+			})
+		}
+		for _, target := range check.Targets {
+			testCase := junitTestCase{
+				Name:      target.Name,
+				ClassName: check.Name,
+				Time:      fmt.Sprintf("%.3f", target.AvgRTTMs/1000),
+			}
+			if !target.Passed {
+				message := target.Error
+				if message == "" {
+					message = fmt.Sprintf("lost %d of %d packets", target.Lost, target.Sent)
+				}
+				testCase.Failure = &junitFailure{
+					Message: message,
+					Text:    fmt.Sprintf("address: %s\nsent: %d\nlost: %d\navg rtt: %.2fms", target.Address, target.Sent, target.Lost, target.AvgRTTMs),
+				}
+			}
+			suite.Cases = append(suite.Cases, testCase)
+		}
+		for i := range suite.Cases {
+			if suite.Cases[i].Failure == nil {
+				continue
+			}
+			if check.Policy != PolicyCritical {
+				suite.Cases[i].Failure.Message = check.Policy + ": " + suite.Cases[i].Failure.Message
+				suite.Cases[i].Skipped, suite.Cases[i].Failure = suite.Cases[i].Failure, nil
+				continue
+			}
+			suite.Failures++
+		}
+		suite.Tests = len(suite.Cases)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+func markdownReport(report *Report) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Network pinger report: %s\n\n", report.NodeName)
+	fmt.Fprintf(&buf, "Started %s, took %s, result **%s**.\n\n",
+		report.StartTime.UTC().Format(time.RFC3339), report.Duration.Round(time.Millisecond), passOrFail(report.Passed))
+
+	buf.WriteString("| Check | Policy | Result | Targets | Failed |\n|---|---|---|---|---|\n")
+	for _, check := range report.Checks {
+		failed := 0
+		for _, target := range check.Targets {
+			if !target.Passed {
+				failed++
+			}
+		}
+		fmt.Fprintf(&buf, "| %s | %s | %s | %d | %d |\n", check.Name, check.Policy, passOrFail(check.Passed), len(check.Targets), failed)
+	}
+
+	for _, check := range report.Checks {
+		if check.Error == "" && len(check.Targets) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "\n## %s\n\n", check.Name)
+		if check.Error != "" {
+			fmt.Fprintf(&buf, "Error: `%s`\n\n", markdownEscape(check.Error))
+		}
+		if len(check.Targets) == 0 {
+			continue
+		}
+		buf.WriteString("| Target | Address | Result | Sent | Lost | Loss | Avg RTT | Error |\n|---|---|---|---|---|---|---|---|\n")
+		for _, target := range check.Targets {
+			fmt.Fprintf(&buf, "| %s | %s | %s | %d | %d | %.1f%% | %.2fms | %s |\n",
+				markdownEscape(target.Name), target.Address, passOrFail(target.Passed),
+				target.Sent, target.Lost, target.LossPercent, target.AvgRTTMs, markdownEscape(target.Error))
+		}
+	}
+	return buf.Bytes()
+}
+
+func passOrFail(passed bool) string {
+	if passed {
+		return "PASS"
+	}
+	return "FAIL"
+}
+
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
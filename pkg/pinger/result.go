@@ -0,0 +1,157 @@
+package pinger
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wenwenxiong/network-pinger/pkg/pmtu"
+	"github.com/wenwenxiong/network-pinger/pkg/traceroute"
+)
+
+const (
+	CheckAPIServer = "apiserver"
+	CheckDNS       = "dns"
+	CheckExtDNS    = "externaldns"
+	CheckPod       = "pod"
+	CheckNode      = "node"
+	CheckIP        = "ip"
+	CheckTarget    = "target"
+	CheckExternal  = "external"
+	CheckIPAudit   = "ipaudit"
+	CheckSubnet    = "subnet"
+)
+
+// ProbeResult is the outcome of one check against one target.
+// NodeIP is the address of the node a probed pod runs on, MeshType is set for probes of mesh
+// peers and ProbeType for declared targets. Started is when the probe began and Timestamp
+// when its result was recorded.
+type ProbeResult struct {
+	Check     string            `json:"check"`
+	Name      string            `json:"name,omitempty"`
+	Namespace string            `json:"namespace,omitempty"`
+	NodeName  string            `json:"nodeName,omitempty"`
+	NodeIP    string            `json:"nodeIP,omitempty"`
+	Address   string            `json:"address,omitempty"`
+	MeshType  string            `json:"meshType,omitempty"`
+	ProbeType string            `json:"probeType,omitempty"`
+	Topology  map[string]string `json:"topology,omitempty"`
+	Sent      int               `json:"sent"`
+	Lost      int               `json:"lost"`
+	AvgRTT    time.Duration     `json:"avgRtt"`
+	Healthy   bool              `json:"healthy"`
+	Error     string            `json:"error,omitempty"`
+	Started   time.Time         `json:"started"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// Key identifies the target across cycles.
+func (r ProbeResult) Key() string {
+	return r.Check + "/" + r.Namespace + "/" + r.Name + "/" + r.Address
+}
+
+// TargetState is the latest result of a target and when its reachability last changed,
+// Trace is the path traced since the target became unreachable and PathMTU the last discovered path mtu.
+type TargetState struct {
+	ProbeResult
+	LastTransitionTime time.Time          `json:"lastTransitionTime"`
+	Trace              *traceroute.Result `json:"trace,omitempty"`
+	PathMTU            *pmtu.Result       `json:"pathMTU,omitempty"`
+}
+
+// resultStore keeps the latest result of every target probed by this pinger.
+type resultStore struct {
+	mu     sync.RWMutex
+	latest map[string]*TargetState
+}
+
+func newResultStore() *resultStore {
+	return &resultStore{latest: make(map[string]*TargetState)}
+}
+
+// Record makes the store the first result sink, the one the PingResult, the report and the
+// results api read from.
+func (s *resultStore) Record(r ProbeResult) {
+	if r.Timestamp.IsZero() {
+		r.Timestamp = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.latest[r.Key()]
+	state := &TargetState{ProbeResult: r, LastTransitionTime: r.Timestamp}
+	if ok && previous.Healthy == r.Healthy {
+		state.LastTransitionTime = previous.LastTransitionTime
+	}
+	if ok && !r.Healthy {
+		state.Trace = previous.Trace
+	}
+	if ok {
+		state.PathMTU = previous.PathMTU
+	}
+	s.latest[r.Key()] = state
+}
+
+// setTrace attaches the trace to the target while it is still unreachable.
+func (s *resultStore) setTrace(key string, trace *traceroute.Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state, ok := s.latest[key]; ok && !state.Healthy {
+		state.Trace = trace
+	}
+}
+
+func (s *resultStore) setPathMTU(key string, result *pmtu.Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state, ok := s.latest[key]; ok {
+		state.PathMTU = result
+	}
+}
+
+// snapshot returns the states updated within ttl, older ones belong to targets that are gone.
+func (s *resultStore) snapshot(ttl time.Duration) []TargetState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states := make([]TargetState, 0, len(s.latest))
+	for key, state := range s.latest {
+		if time.Since(state.Timestamp) > ttl {
+			delete(s.latest, key)
+			continue
+		}
+		states = append(states, *state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Key() < states[j].Key()
+	})
+	return states
+}
+
+// recordResult completes the result and hands it to every sink in order.
+func (config *Configuration) recordResult(r ProbeResult) {
+	// probes canceled by the shutdown fail without telling anything about the network
+	select {
+	case <-config.done:
+		return
+	default:
+	}
+	if r.Timestamp.IsZero() {
+		r.Timestamp = time.Now()
+	}
+	if r.NodeName != "" && r.Topology == nil && config.topology != nil {
+		r.Topology = config.topology.node(r.NodeName)
+	}
+	for _, sink := range config.sinks {
+		sink.Record(r)
+	}
+}
+
+// resultTTL is how long a target is remembered after its last probe.
+func (config *Configuration) resultTTL() time.Duration {
+	ttl := 3 * config.interval()
+	if ttl < time.Minute {
+		ttl = time.Minute
+	}
+	return ttl
+}
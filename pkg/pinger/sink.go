@@ -0,0 +1,246 @@
+package pinger
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/wenwenxiong/network-pinger/pkg/notifier"
+)
+
+// ResultSink receives the reachability result of every probed target once it is recorded. The
+// dscp, path mtu, traceroute, ip audit and subnet results are not reachability results and only
+// set their prometheus metrics, which the otlp metrics and the job mode pushes carry, while the
+// trace and path mtu of a target show in its TargetState. Record is called from the probe
+// goroutines, so it must be safe for concurrent use and should return quickly.
+type ResultSink interface {
+	Record(result ProbeResult)
+}
+
+// ResultSinkFunc adapts a function to a ResultSink.
+type ResultSinkFunc func(result ProbeResult)
+
+func (f ResultSinkFunc) Record(result ProbeResult) {
+	f(result)
+}
+
+// initSinks puts the built-in sinks in front of the injected ones, the result store first so
+// the sinks after it see the state of the target updated.
+func (config *Configuration) initSinks(extra []ResultSink) error {
+	config.sinks = []ResultSink{config.results, prometheusSink{config: config}, logSink{}}
+	if config.traces != nil {
+		config.sinks = append(config.sinks, config.traces)
+	}
+	if config.eventRecorder != nil {
+		config.sinks = append(config.sinks, newEventSink(config))
+	}
+	if config.notifier != nil {
+		config.sinks = append(config.sinks, notifierSink{config: config})
+	}
+	if config.otel != nil && config.EnableOTLPTraces {
+		config.sinks = append(config.sinks, config.otel)
+	}
+	exporters, err := config.initExporters()
+	if err != nil {
+		return err
+	}
+	config.sinks = append(config.sinks, exporters...)
+	if config.history != nil {
+		config.sinks = append(config.sinks, config.history)
+	}
+	if config.ResultFile != "" {
+		sink, err := newFileSink(config.ResultFile)
+		if err != nil {
+			return err
+		}
+		config.sinks = append(config.sinks, sink)
+	}
+	config.sinks = append(config.sinks, extra...)
+	return nil
+}
+
+// prometheusSink exports the results as the metrics of their check.
+type prometheusSink struct {
+	config *Configuration
+}
+
+func (s prometheusSink) Record(r ProbeResult) {
+	config := s.config
+	latency := float64(r.AvgRTT) / float64(time.Millisecond)
+	switch {
+	case r.Check == CheckAPIServer:
+		if r.Healthy {
+			SetApiserverHealthyMetrics(config.NodeName, latency)
+		} else {
+			SetApiserverUnhealthyMetrics(config.NodeName)
+		}
+	case r.Check == CheckDNS:
+		if r.Healthy {
+			SetInternalDNSHealthyMetrics(config.NodeName, latency)
+		} else {
+			SetInternalDNSUnhealthyMetrics(config.NodeName)
+		}
+	case r.Check == CheckExtDNS:
+		if r.Healthy {
+			SetExternalDNSHealthyMetrics(config.NodeName, latency)
+		} else {
+			SetExternalDNSUnhealthyMetrics(config.NodeName)
+		}
+	case r.MeshType != "":
+		if r.Error != "" {
+			SetMeshUnreachableMetrics(config.NodeName, r.NodeName, r.MeshType)
+		} else {
+			SetMeshMetrics(config.NodeName, r.NodeName, r.MeshType, latency, r.Lost, r.Sent)
+		}
+	case r.Error != "":
+		// the probe failed before sending anything
+	case r.Check == CheckPod:
+		SetPodPingMetrics(config.NodeName, config.HostIP, config.PodName, r.NodeName, r.NodeIP, r.Address, latency, r.Lost, r.Sent)
+	case r.Check == CheckIP:
+		SetIPPingMetrics(config.NodeName, config.HostIP, config.PodName, r.Address, latency, r.Lost, r.Sent)
+	case r.Check == CheckExternal:
+		SetExternalPingMetrics(config.NodeName, config.HostIP, config.PodName, r.Address, latency, r.Lost, r.Sent)
+	case r.Check == CheckNode:
+		SetNodePingMetrics(config.NodeName, config.HostIP, config.PodName, r.Name, r.Address, latency, r.Lost, r.Sent)
+	case r.Check == CheckTarget:
+		SetTargetPingMetrics(config.NodeName, config.HostIP, config.PodName, r.Namespace+"/"+r.Name, r.Address, r.ProbeType, latency, r.Lost, r.Sent, r.Healthy)
+	}
+	config.observeTopology(r)
+}
+
+// logSink logs the results of the probes that ran, failed probes log their error themselves.
+type logSink struct{}
+
+func (logSink) Record(r ProbeResult) {
+	if r.Error != "" {
+		return
+	}
+	latency := float64(r.AvgRTT) / float64(time.Millisecond)
+	switch {
+	case r.Check == CheckAPIServer:
+		klog.Infof("connect to apiserver success in %.2fms", latency)
+	case r.Check == CheckDNS:
+		klog.Infof("resolve dns %s in %.2fms", r.Name, latency)
+	case r.Check == CheckExtDNS:
+		klog.Infof("resolve external dns %s in %.2fms", r.Name, latency)
+	case r.MeshType != "":
+		klog.Infof("ping mesh %s: %s %s, count: %d, loss count %d, average rtt %.2fms",
+			r.MeshType, r.NodeName, r.Address, r.Sent, r.Lost, latency)
+	case r.Check == CheckPod:
+		klog.Infof("ping pod: %s %s, count: %d, loss count %d, average rtt %.2fms",
+			r.Name, r.Address, r.Sent, r.Lost, latency)
+	case r.Check == CheckIP:
+		klog.Infof("ping IP: %s, count: %d, loss count %d, average rtt %.2fms",
+			r.Address, r.Sent, r.Lost, latency)
+	case r.Check == CheckExternal:
+		klog.Infof("ping external: %s, count: %d, loss count %d, average rtt %.2fms",
+			r.Address, r.Sent, r.Lost, latency)
+	case r.Check == CheckNode:
+		klog.Infof("ping node: %s %s, count: %d, loss count %d, average rtt %.2fms",
+			r.Name, r.Address, r.Sent, r.Lost, latency)
+	case r.Check == CheckTarget:
+		klog.Infof("probe target %s/%s %s %s, count: %d, loss count %d, average rtt %.2fms, healthy %v",
+			r.Namespace, r.Name, r.ProbeType, r.Address, r.Sent, r.Lost, latency, r.Healthy)
+	default:
+		klog.Infof("probe %s %s, count: %d, loss count %d, average rtt %.2fms, healthy %v",
+			r.Check, targetDisplayName(r), r.Sent, r.Lost, latency, r.Healthy)
+	}
+}
+
+// notifierSink feeds the incident tracking of the webhooks.
+type notifierSink struct {
+	config *Configuration
+}
+
+func (s notifierSink) Record(r ProbeResult) {
+	s.config.notifier.Observe(notifier.Observation{
+		Key:        r.Key(),
+		Check:      r.Check,
+		Target:     targetDisplayName(r),
+		Address:    r.Address,
+		SourceNode: s.config.NodeName,
+		Healthy:    r.Healthy,
+		Sent:       r.Sent,
+		Lost:       r.Lost,
+		AvgRTT:     r.AvgRTT,
+		Error:      r.Error,
+		Timestamp:  r.Timestamp,
+	})
+}
+
+// fileSink appends every result as a json line to a file, or writes it to stdout.
+type fileSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newFileSink(path string) (*fileSink, error) {
+	if path == "-" {
+		return &fileSink{w: os.Stdout}, nil
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		klog.Errorf("failed to open result file %s: %v", path, err)
+		return nil, err
+	}
+	return &fileSink{w: file}, nil
+}
+
+func (s *fileSink) Record(r ProbeResult) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		klog.Errorf("failed to encode result of %s: %v", targetDisplayName(r), err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err = s.w.Write(append(data, '\n')); err != nil {
+		klog.Errorf("failed to write result of %s: %v", targetDisplayName(r), err)
+	}
+}
+
+// dimension is a source or target dimension of a result, named like the prometheus labels.
+type dimension struct {
+	name, value string
+}
+
+// resultDimensions are the dimensions the exporters other than prometheus tag results with,
+// empty ones are left out.
+func (config *Configuration) resultDimensions(r ProbeResult) []dimension {
+	target := r.Name
+	if r.Namespace != "" {
+		target = r.Namespace + "/" + r.Name
+	}
+	all := []dimension{
+		{"src_node_name", config.NodeName},
+		{"src_node_ip", config.HostIP},
+		{"src_pod_name", config.PodName},
+		{"check", r.Check},
+		{"target", target},
+		{"target_address", r.Address},
+		{"target_node_name", r.NodeName},
+		{"target_node_ip", r.NodeIP},
+		{"mesh_type", r.MeshType},
+		{"probe_type", r.ProbeType},
+	}
+	levels := make([]string, 0, len(r.Topology))
+	for level := range r.Topology {
+		levels = append(levels, level)
+	}
+	sort.Strings(levels)
+	for _, level := range levels {
+		all = append(all, dimension{"target_" + level, r.Topology[level]})
+	}
+	dimensions := all[:0]
+	for _, d := range all {
+		if d.value != "" {
+			dimensions = append(dimensions, d)
+		}
+	}
+	return dimensions
+}
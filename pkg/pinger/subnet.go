@@ -0,0 +1,111 @@
+package pinger
+
+import (
+	"math/big"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	"github.com/wenwenxiong/network-pinger/pkg/util"
+	networkv1 "pkg/apis/network/v1"
+)
+
+// SubnetUsage is the capacity of one protocol of a subnet. ip crds only carry the ipv4
+// address, so the allocations of ipv6 blocks are unknown and only their capacity is exported.
+type SubnetUsage struct {
+	Subnet           string
+	Protocol         string
+	CIDR             string
+	Total            *big.Int
+	Allocated        int
+	AllocatedUnknown bool
+}
+
+func (u SubnetUsage) Free() *big.Int {
+	free := new(big.Int).Sub(u.Total, big.NewInt(int64(u.Allocated)))
+	if free.Sign() < 0 {
+		return big.NewInt(0)
+	}
+	return free
+}
+
+func (u SubnetUsage) Utilization() float64 {
+	if u.Total.Sign() == 0 {
+		return 0
+	}
+	ratio, _ := new(big.Rat).SetFrac(big.NewInt(int64(u.Allocated)), u.Total).Float64()
+	return ratio
+}
+
+func collectSubnetMetrics(config *Configuration) error {
+	if !config.networkInformersSynced() {
+		klog.Infof("subnet and ip informers not synced yet, skip subnet capacity")
+		return nil
+	}
+	subnets, err := config.SubnetLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("failed to list subnets: %v", err)
+		return err
+	}
+	ips, err := config.IPLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("failed to list ips: %v", err)
+		return err
+	}
+
+	ResetSubnetMetrics()
+	for _, usage := range subnetUsages(subnets, ips) {
+		if usage.AllocatedUnknown {
+			klog.V(3).Infof("subnet %s %s %s: total %s, allocated unknown", usage.Subnet, usage.Protocol, usage.CIDR, usage.Total.String())
+			SetSubnetMetrics(usage)
+			continue
+		}
+		klog.V(3).Infof("subnet %s %s %s: total %s, allocated %d, utilization %.2f",
+			usage.Subnet, usage.Protocol, usage.CIDR, usage.Total.String(), usage.Allocated, usage.Utilization())
+		SetSubnetMetrics(usage)
+	}
+	return nil
+}
+
+func subnetUsages(subnets []*networkv1.Subnet, ips []*networkv1.IP) []SubnetUsage {
+	var usages []SubnetUsage
+	for _, subnet := range subnets {
+		gateways := strings.Split(subnet.Spec.Gateway, ",")
+		for _, cidr := range strings.Split(subnet.Spec.CIDRBlock, ",") {
+			cidr = strings.TrimSpace(cidr)
+			protocol := util.CheckProtocol(cidr)
+			if protocol == "" {
+				klog.Warningf("invalid cidr %q of subnet %s", cidr, subnet.Name)
+				continue
+			}
+			var gateway string
+			for _, gw := range gateways {
+				if util.CheckProtocol(gw) == protocol {
+					gateway = strings.TrimSpace(gw)
+				}
+			}
+
+			usage := SubnetUsage{
+				Subnet:   subnet.Name,
+				Protocol: protocol,
+				CIDR:     cidr,
+				Total:    util.UsableIPCount(cidr, gateway, subnet.Spec.ExcludeIps),
+			}
+			if protocol == util.ProtocolIPv6 {
+				usage.AllocatedUnknown = true
+				usages = append(usages, usage)
+				continue
+			}
+			allocated := make(map[string]bool)
+			for _, ip := range ips {
+				if subnetMatches(subnet, ip.Spec.Subnet) && util.CIDRContainIP(cidr, ip.Spec.V4IPAddress) {
+					allocated[ip.Spec.V4IPAddress] = true
+				}
+			}
+			usage.Allocated = len(allocated)
+			usages = append(usages, usage)
+		}
+	}
+	return usages
+}
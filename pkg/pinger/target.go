@@ -0,0 +1,302 @@
+package pinger
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"github.com/wenwenxiong/network-pinger/pkg/util"
+	networkv1 "pkg/apis/network/v1"
+)
+
+// targetController runs one probe loop per PingTarget and restarts it whenever the spec changes.
+type targetController struct {
+	config *Configuration
+
+	mu      sync.Mutex
+	workers map[string]*targetWorker
+}
+
+type targetWorker struct {
+	generation int64
+	stopCh     chan struct{}
+}
+
+func newTargetController(config *Configuration) *targetController {
+	return &targetController{
+		config:  config,
+		workers: make(map[string]*targetWorker),
+	}
+}
+
+func (c *targetController) run(stopCh <-chan struct{}) {
+	informer := c.config.networkInformerFactory.Mec().V1().PingTargets().Informer()
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.syncTarget(obj.(*networkv1.PingTarget))
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			c.syncTarget(newObj.(*networkv1.PingTarget))
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if target, ok := obj.(*networkv1.PingTarget); ok {
+				c.removeTarget(target.Namespace + "/" + target.Name)
+			}
+		},
+	}); err != nil {
+		util.LogFatalAndExit(err, "failed to add ping target event handler")
+	}
+
+	go func() {
+		<-stopCh
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for key, worker := range c.workers {
+			close(worker.stopCh)
+			delete(c.workers, key)
+		}
+	}()
+}
+
+func (c *targetController) syncTarget(target *networkv1.PingTarget) {
+	key := target.Namespace + "/" + target.Name
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if worker, ok := c.workers[key]; ok {
+		if worker.generation == target.Generation {
+			return
+		}
+		klog.Infof("ping target %s changed, restart probing", key)
+		close(worker.stopCh)
+		DeleteTargetPingMetrics(key)
+	}
+
+	worker := &targetWorker{generation: target.Generation, stopCh: make(chan struct{})}
+	c.workers[key] = worker
+	target = target.DeepCopy()
+	interval := c.config.interval()
+	if target.Spec.IntervalSeconds > 0 {
+		interval = time.Duration(target.Spec.IntervalSeconds) * time.Second
+	}
+	klog.Infof("start to probe ping target %s every %v", key, interval)
+	var probed []string
+	go wait.UntilWithContext(wait.ContextForChannel(worker.stopCh), func(ctx context.Context) {
+		addresses, err := targetAddresses(c.config, target)
+		if err != nil {
+			klog.Errorf("failed to resolve addresses of ping target %s: %v", key, err)
+			return
+		}
+		// the series of the pods and nodes no longer selected would stay at their last value
+		for _, address := range probed {
+			if !util.ContainsString(addresses, address) {
+				DeleteTargetAddressPingMetrics(key, address)
+			}
+		}
+		probed = addresses
+		_ = pingTargetAddresses(ctx, c.config, target, addresses)
+	}, interval)
+}
+
+func (c *targetController) removeTarget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if worker, ok := c.workers[key]; ok {
+		klog.Infof("ping target %s deleted, stop probing", key)
+		close(worker.stopCh)
+		delete(c.workers, key)
+		DeleteTargetPingMetrics(key)
+	}
+}
+
+// pingTargets probes every ping target once, job mode uses it in place of the target workers.
+func pingTargets(ctx context.Context, config *Configuration) error {
+	klog.Infof("start to check ping targets")
+	targets, err := config.pingTargetLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("failed to list ping targets: %v", err)
+		return err
+	}
+
+	var errs []error
+	for _, target := range targets {
+		if err = pingTarget(ctx, config, target); err != nil {
+			errs = append(errs, fmt.Errorf("ping target %s/%s: %v", target.Namespace, target.Name, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// targetAddresses resolves the selectors and static addresses of the target.
+func targetAddresses(config *Configuration, target *networkv1.PingTarget) ([]string, error) {
+	var addresses []string
+	if target.Spec.PodSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(target.Spec.PodSelector)
+		if err != nil {
+			return nil, err
+		}
+		pods, err := config.podLister.Pods(target.Namespace).List(selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods {
+			for _, podIP := range pod.Status.PodIPs {
+				if util.ContainsString(config.PodProtocols, util.CheckProtocol(podIP.IP)) {
+					addresses = append(addresses, podIP.IP)
+				}
+			}
+		}
+	}
+	if target.Spec.NodeSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(target.Spec.NodeSelector)
+		if err != nil {
+			return nil, err
+		}
+		nodes, err := config.nodeLister.List(selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, no := range nodes {
+			for _, addr := range no.Status.Addresses {
+				if addr.Type == v1.NodeInternalIP && util.ContainsString(config.PodProtocols, util.CheckProtocol(addr.Address)) {
+					addresses = append(addresses, addr.Address)
+				}
+			}
+		}
+	}
+	return append(addresses, target.Spec.Addresses...), nil
+}
+
+func pingTarget(ctx context.Context, config *Configuration, target *networkv1.PingTarget) error {
+	addresses, err := targetAddresses(config, target)
+	if err != nil {
+		klog.Errorf("failed to resolve addresses of ping target %s/%s: %v", target.Namespace, target.Name, err)
+		return err
+	}
+	return pingTargetAddresses(ctx, config, target, addresses)
+}
+
+func pingTargetAddresses(ctx context.Context, config *Configuration, target *networkv1.PingTarget, addresses []string) error {
+	key := target.Namespace + "/" + target.Name
+
+	probeType := target.Spec.ProbeType
+	if probeType == "" {
+		probeType = networkv1.ProbeTypeICMP
+	}
+	profile := config.probeProfile(ProfileExternal)
+	if target.Spec.Count > 0 {
+		profile.Count = int(target.Spec.Count)
+	}
+	if target.Spec.TimeoutSeconds > 0 {
+		profile.Timeout = metav1.Duration{Duration: time.Duration(target.Spec.TimeoutSeconds) * time.Second}
+	}
+
+	dscpClasses := config.dscpClasses()
+	if target.Spec.DSCPClasses != nil {
+		dscpClasses = make([]int, 0, len(target.Spec.DSCPClasses))
+		for _, dscp := range target.Spec.DSCPClasses {
+			dscpClasses = append(dscpClasses, int(dscp))
+		}
+		if err := validateDSCPClasses(dscpClasses); err != nil {
+			klog.Errorf("invalid dscp classes of ping target %s: %v", key, err)
+			return err
+		}
+	}
+
+	var errs []error
+	var err error
+	for _, address := range addresses {
+		result := ProbeResult{Check: CheckTarget, Name: target.Name, Namespace: target.Namespace, Address: address, ProbeType: probeType, Started: time.Now()}
+		var sent, recv int
+		var avgRtt time.Duration
+		switch probeType {
+		case networkv1.ProbeTypeICMP:
+			sent, recv, avgRtt, err = icmpProbe(ctx, address, profile)
+		case networkv1.ProbeTypeTCP:
+			sent, recv, avgRtt, err = tcpProbe(ctx, address, int(target.Spec.Port), profile.Count, profile.Timeout.Duration)
+		default:
+			err = fmt.Errorf("unsupported probe type %q", probeType)
+		}
+		if err != nil {
+			klog.Errorf("failed to probe %s of ping target %s: %v", address, key, err)
+			result.Error = err.Error()
+			config.recordResult(result)
+			errs = append(errs, fmt.Errorf("%s: %v", address, err))
+			continue
+		}
+
+		lost := int(math.Abs(float64(sent - recv)))
+		latency := float64(avgRtt) / float64(time.Millisecond)
+		healthy := targetHealthy(target.Spec.Thresholds, sent, lost, latency)
+		if !healthy {
+			errs = append(errs, fmt.Errorf("%s: ping failed", address))
+		}
+		result.Sent, result.Lost, result.AvgRTT, result.Healthy = sent, lost, avgRtt, healthy
+		config.recordResult(result)
+		if probeType == networkv1.ProbeTypeICMP {
+			probeDSCP(ctx, config, result, profile, dscpClasses)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func targetHealthy(thresholds networkv1.PingThresholds, sent, lost int, latency float64) bool {
+	if sent == 0 {
+		return false
+	}
+	lossPercent := float64(lost) * 100 / float64(sent)
+	if thresholds.MaxLossPercent > 0 {
+		if lossPercent > float64(thresholds.MaxLossPercent) {
+			return false
+		}
+	} else if lost != 0 {
+		return false
+	}
+	if thresholds.MaxRTTMilliseconds > 0 && latency > float64(thresholds.MaxRTTMilliseconds) {
+		return false
+	}
+	return true
+}
+
+// tcpProbe measures the time to complete a tcp handshake with the address.
+func tcpProbe(ctx context.Context, address string, port, count int, timeout time.Duration) (int, int, time.Duration, error) {
+	if port <= 0 || port > 65535 {
+		return 0, 0, 0, fmt.Errorf("invalid tcp port %d", port)
+	}
+	var recv int
+	var total time.Duration
+	dialer := net.Dialer{Timeout: timeout}
+	for i := 0; i < count; i++ {
+		t1 := time.Now()
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(address, strconv.Itoa(port)))
+		if ctx.Err() != nil {
+			return 0, 0, 0, ctx.Err()
+		}
+		if err != nil {
+			klog.V(3).Infof("tcp probe %s:%d failed: %v", address, port, err)
+			continue
+		}
+		total += time.Since(t1)
+		recv++
+		_ = conn.Close()
+	}
+	if recv == 0 {
+		return count, 0, 0, nil
+	}
+	return count, recv, total / time.Duration(recv), nil
+}
@@ -0,0 +1,86 @@
+package pinger
+
+import (
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	TopologyZone   = "zone"
+	TopologyRegion = "region"
+)
+
+// topologyCache keeps the topology labels of every node, keyed by level: zone, region
+// and the extra levels configured with --topology-labels.
+type topologyCache struct {
+	levels map[string]string
+
+	mu    sync.RWMutex
+	nodes map[string]map[string]string
+}
+
+func newTopologyCache(extraLevels map[string]string) *topologyCache {
+	levels := map[string]string{
+		TopologyZone:   v1.LabelTopologyZone,
+		TopologyRegion: v1.LabelTopologyRegion,
+	}
+	for level, label := range extraLevels {
+		levels[level] = label
+	}
+	return &topologyCache{levels: levels, nodes: make(map[string]map[string]string)}
+}
+
+// refresh reads the topology labels from the node informer shared with the mesh peer selection.
+func (c *topologyCache) refresh(nodeLister listerv1.NodeLister) error {
+	nodes, err := nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("failed to list nodes, %v", err)
+		return err
+	}
+	topology := make(map[string]map[string]string, len(nodes))
+	for _, no := range nodes {
+		values := make(map[string]string, len(c.levels))
+		for level, label := range c.levels {
+			if value := no.Labels[label]; value != "" {
+				values[level] = value
+			}
+		}
+		topology[no.Name] = values
+	}
+
+	c.mu.Lock()
+	c.nodes = topology
+	c.mu.Unlock()
+	return nil
+}
+
+// node returns the topology of the node, nil if the node is unknown or has no topology labels.
+func (c *topologyCache) node(name string) map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.nodes[name]) == 0 {
+		return nil
+	}
+	return c.nodes[name]
+}
+
+// observeTopology aggregates the result into the metrics between the topology of this node
+// and the topology of the probed node, level by level.
+func (config *Configuration) observeTopology(r ProbeResult) {
+	if r.Sent == 0 || len(r.Topology) == 0 {
+		return
+	}
+	src := config.topology.node(config.NodeName)
+	latency := float64(r.AvgRTT) / float64(time.Millisecond)
+	for level, dst := range r.Topology {
+		if src[level] == "" {
+			continue
+		}
+		SetTopologyMetrics(level, src[level], dst, r.Check, latency, r.Lost, r.Sent)
+	}
+}
@@ -0,0 +1,160 @@
+package pinger
+
+import (
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"github.com/wenwenxiong/network-pinger/pkg/traceroute"
+	networkv1 "pkg/apis/network/v1"
+)
+
+// traceManager traces the path to failing targets in the background, at most one trace
+// per target at a time and one per cooldown, so a target that stays down is not traced every cycle.
+// The semaphore bounds the traces running at the same time when many targets fail together.
+type traceManager struct {
+	config    *Configuration
+	opts      traceroute.Options
+	cooldown  time.Duration
+	semaphore chan struct{}
+
+	mu       sync.Mutex
+	inflight map[string]bool
+	last     map[string]time.Time
+	// traced holds the unreachable targets whose traceroute metrics are exported
+	traced map[string]ProbeResult
+}
+
+func newTraceManager(config *Configuration) *traceManager {
+	return &traceManager{
+		config: config,
+		opts: traceroute.Options{
+			Protocol: config.TracerouteProtocol,
+			Port:     config.TraceroutePort,
+			MaxHops:  config.TracerouteMaxHops,
+			Rounds:   config.TracerouteRounds,
+			Timeout:  config.TracerouteTimeout,
+		},
+		cooldown:  config.TracerouteCooldown,
+		semaphore: make(chan struct{}, config.TracerouteConcurrency),
+		inflight:  make(map[string]bool),
+		last:      make(map[string]time.Time),
+		traced:    make(map[string]ProbeResult),
+	}
+}
+
+// Record traces unreachable targets and drops the traceroute metrics of recovered ones.
+func (m *traceManager) Record(r ProbeResult) {
+	if r.Address == "" {
+		return
+	}
+	if !r.Healthy {
+		m.trigger(r)
+		return
+	}
+	key := r.Key()
+	m.mu.Lock()
+	_, traced := m.traced[key]
+	delete(m.traced, key)
+	m.mu.Unlock()
+	if traced {
+		DeleteTracerouteMetrics(r.Check, r.Address)
+	}
+}
+
+// prune forgets the targets no longer probed and drops their traceroute metrics.
+func (m *traceManager) prune(states []TargetState) {
+	known := make(map[string]bool, len(states))
+	for _, state := range states {
+		known[state.Key()] = true
+	}
+	var gone []ProbeResult
+	m.mu.Lock()
+	for key := range m.last {
+		if !known[key] && !m.inflight[key] {
+			delete(m.last, key)
+		}
+	}
+	for key, r := range m.traced {
+		if !known[key] {
+			delete(m.traced, key)
+			gone = append(gone, r)
+		}
+	}
+	m.mu.Unlock()
+	for _, r := range gone {
+		DeleteTracerouteMetrics(r.Check, r.Address)
+	}
+}
+
+func (m *traceManager) trigger(r ProbeResult) {
+	key := r.Key()
+	m.mu.Lock()
+	if m.inflight[key] || time.Since(m.last[key]) < m.cooldown {
+		m.mu.Unlock()
+		return
+	}
+	select {
+	case m.semaphore <- struct{}{}:
+	default:
+		m.mu.Unlock()
+		klog.V(3).Infof("%d traceroutes running, skip tracing %s %s", cap(m.semaphore), r.Check, targetDisplayName(r))
+		return
+	}
+	m.inflight[key] = true
+	m.mu.Unlock()
+
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			delete(m.inflight, key)
+			m.last[key] = time.Now()
+			m.mu.Unlock()
+			<-m.semaphore
+		}()
+
+		klog.Infof("start to trace %s %s after failed probe", r.Check, targetDisplayName(r))
+		// traces are canceled with the pinger
+		result, err := traceroute.Run(wait.ContextForChannel(m.config.done), r.Address, m.opts)
+		if err != nil {
+			klog.Errorf("failed to trace %s: %v", r.Address, err)
+			return
+		}
+		klog.Infof("%s", result.String())
+		m.config.results.setTrace(key, result)
+
+		lastHop, lastTTL := "", 0
+		if hop := result.LastHop(); hop != nil {
+			lastHop, lastTTL = hop.Address, hop.TTL
+		}
+		m.mu.Lock()
+		m.traced[key] = r
+		m.mu.Unlock()
+		SetTracerouteMetrics(m.config.NodeName, r.Check, targetDisplayName(r), r.Address, lastHop, lastTTL, result.Reached)
+	}()
+}
+
+func traceResult(result *traceroute.Result) *networkv1.TraceResult {
+	if result == nil {
+		return nil
+	}
+	trace := &networkv1.TraceResult{
+		Protocol: result.Protocol,
+		Reached:  result.Reached,
+		Hops:     make([]networkv1.TraceHop, 0, len(result.Hops)),
+		Time:     metav1.NewTime(result.Time),
+	}
+	for _, hop := range result.Hops {
+		trace.Hops = append(trace.Hops, networkv1.TraceHop{
+			TTL:     int32(hop.TTL),
+			Address: hop.Address,
+			Sent:    int32(hop.Sent),
+			Lost:    int32(hop.Lost),
+			AvgRTT:  metav1.Duration{Duration: hop.AvgRTT},
+		})
+	}
+	return trace
+}
@@ -0,0 +1,298 @@
+package pmtu
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+
+	"github.com/wenwenxiong/network-pinger/pkg/util"
+)
+
+const (
+	minIPv4MTU = 576
+	minIPv6MTU = 1280
+
+	maxIPv4MTU = 65535
+
+	ipv4Header = 20
+	ipv6Header = 40
+	icmpHeader = 8
+
+	protocolICMPv4 = 1
+	protocolICMPv6 = 58
+)
+
+type Options struct {
+	Timeout time.Duration
+	Retries int
+}
+
+// Result is the path mtu towards a target next to the mtu of the interface the route leaves through.
+type Result struct {
+	Target    string
+	Interface string
+	LocalMTU  int
+	PathMTU   int
+	// Reported is true when a router answered with fragmentation needed or packet too big,
+	// false when the path mtu was found from lost probes only, which points to a black hole.
+	Reported bool
+	Time     time.Time
+}
+
+// Mismatch is true when large packets sent through the interface do not make it to the target.
+func (r *Result) Mismatch() bool {
+	return r.PathMTU < r.LocalMTU
+}
+
+// Discover searches the largest packet that reaches the target with the do not fragment bit,
+// between the minimum mtu of the protocol and the mtu of the local interface.
+func Discover(target string, opts Options) (*Result, error) {
+	ip := net.ParseIP(target)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip %q", target)
+	}
+	if opts.Timeout <= 0 || opts.Retries <= 0 {
+		return nil, fmt.Errorf("timeout and retries of a path mtu discovery must be positive")
+	}
+	iface, err := routeInterface(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := newProber(ip, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer p.close()
+
+	result := &Result{Target: target, Interface: iface.Name, LocalMTU: iface.MTU, Time: time.Now()}
+	low, high := minIPv4MTU, iface.MTU
+	if p.ipv6 {
+		low = minIPv6MTU
+	} else if high > maxIPv4MTU {
+		// the total length of an ipv4 packet does not fit loopback mtus
+		high = maxIPv4MTU
+		result.LocalMTU = maxIPv4MTU
+	}
+	if high < low {
+		high = low
+	}
+	// the minimum has to pass, otherwise the target is unreachable rather than the path too narrow
+	ok, _, err := p.try(low)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("no reply from %s to packets of %d bytes", target, low)
+	}
+	for low < high {
+		size := (low + high + 1) / 2
+		ok, reported, err := p.try(size)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case ok:
+			low = size
+		case reported > 0 && reported < size:
+			result.Reported = true
+			high = reported
+			if high < low {
+				high = low
+			}
+		default:
+			high = size - 1
+		}
+	}
+	result.PathMTU = low
+	return result, nil
+}
+
+// routeInterface finds the interface the kernel routes the target through.
+func routeInterface(target net.IP) (*net.Interface, error) {
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: target, Port: 9})
+	if err != nil {
+		return nil, err
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	_ = conn.Close()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ifaces {
+		addrs, err := ifaces[i].Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(local) {
+				return &ifaces[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no interface has the source address %s of the route to %s", local, target)
+}
+
+type prober struct {
+	target net.IP
+	ipv6   bool
+	opts   Options
+	id     int
+	seq    int
+	conn   *net.IPConn
+}
+
+func newProber(target net.IP, opts Options) (*prober, error) {
+	p := &prober{
+		target: target,
+		ipv6:   target.To4() == nil,
+		opts:   opts,
+		id:     util.NextICMPID(),
+	}
+	network, address := "ip4:icmp", "0.0.0.0"
+	if p.ipv6 {
+		network, address = "ip6:ipv6-icmp", "::"
+	}
+	conn, err := net.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+	p.conn = conn.(*net.IPConn)
+
+	// probe mode sets the do not fragment bit and ignores the path mtu cached by the kernel,
+	// so every size is really sent
+	raw, err := p.conn.SyscallConn()
+	if err != nil {
+		_ = p.conn.Close()
+		return nil, err
+	}
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		if p.ipv6 {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_PROBE)
+		} else {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_PROBE)
+		}
+	})
+	if err == nil {
+		err = sockErr
+	}
+	if err != nil {
+		_ = p.conn.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *prober) close() {
+	_ = p.conn.Close()
+}
+
+// try sends echo requests of the packet size, ok is true when one was answered and
+// reported is the mtu of the next hop if a router refused the packet.
+func (p *prober) try(size int) (bool, int, error) {
+	header, proto := ipv4Header+icmpHeader, protocolICMPv4
+	msg := icmp.Message{Type: ipv4.ICMPTypeEcho}
+	if p.ipv6 {
+		header, proto = ipv6Header+icmpHeader, protocolICMPv6
+		msg.Type = ipv6.ICMPTypeEchoRequest
+	}
+
+	reported := 0
+	for i := 0; i < p.opts.Retries; i++ {
+		p.seq = (p.seq + 1) & 0xffff
+		msg.Body = &icmp.Echo{ID: p.id, Seq: p.seq, Data: make([]byte, size-header)}
+		data, err := msg.Marshal(nil)
+		if err != nil {
+			return false, 0, err
+		}
+		if _, err = p.conn.WriteTo(data, &net.IPAddr{IP: p.target}); err != nil {
+			if errors.Is(err, syscall.EMSGSIZE) {
+				return false, 0, nil
+			}
+			return false, 0, err
+		}
+		ok, mtu, err := p.wait(proto)
+		if err != nil {
+			return false, 0, err
+		}
+		if ok {
+			return true, 0, nil
+		}
+		if mtu > 0 {
+			reported = mtu
+			break
+		}
+	}
+	return false, reported, nil
+}
+
+func (p *prober) wait(proto int) (bool, int, error) {
+	deadline := time.Now().Add(p.opts.Timeout)
+	if err := p.conn.SetReadDeadline(deadline); err != nil {
+		return false, 0, err
+	}
+	buf := make([]byte, 65536)
+	for {
+		n, peer, err := p.conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return false, 0, nil
+			}
+			return false, 0, err
+		}
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil {
+			continue
+		}
+		switch body := msg.Body.(type) {
+		case *icmp.Echo:
+			if (msg.Type == ipv4.ICMPTypeEchoReply || msg.Type == ipv6.ICMPTypeEchoReply) &&
+				body.ID == p.id && body.Seq == p.seq && peer.(*net.IPAddr).IP.Equal(p.target) {
+				return true, 0, nil
+			}
+		case *icmp.PacketTooBig:
+			if p.quotesProbe(body.Data) {
+				return false, body.MTU, nil
+			}
+		case *icmp.DstUnreach:
+			// fragmentation needed carries the mtu of the next hop in the unused header field,
+			// code 4 of icmpv6 is port unreachable and carries no mtu
+			if !p.ipv6 && msg.Code == 4 && n >= 8 && p.quotesProbe(body.Data) {
+				return false, int(buf[6])<<8 | int(buf[7]), nil
+			}
+		}
+	}
+}
+
+// quotesProbe tells whether the original datagram quoted by an icmp error is the last probe.
+func (p *prober) quotesProbe(data []byte) bool {
+	var payload []byte
+	if p.ipv6 {
+		if len(data) < ipv6Header+icmpHeader || !net.IP(data[24:40]).Equal(p.target) {
+			return false
+		}
+		payload = data[ipv6Header:]
+	} else {
+		if len(data) < ipv4Header {
+			return false
+		}
+		ihl := int(data[0]&0x0f) * 4
+		if len(data) < ihl+icmpHeader || !net.IP(data[16:20]).Equal(p.target) {
+			return false
+		}
+		payload = data[ihl:]
+	}
+	id := int(payload[4])<<8 | int(payload[5])
+	seq := int(payload[6])<<8 | int(payload[7])
+	return id == p.id && seq == p.seq
+}
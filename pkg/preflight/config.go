@@ -0,0 +1,110 @@
+package preflight
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+
+	networkClientset "pkg/client/clientset/versioned"
+)
+
+type Configuration struct {
+	KubeConfigFile  string
+	KubeClient      kubernetes.Interface
+	NetworkClient   networkClientset.Interface
+	Namespace       string
+	PodSelector     string
+	InternalDNS     string
+	ExternalAddress string
+	MTU             int
+	Timeout         time.Duration
+	ProbeTimeout    time.Duration
+	Parallelism     int
+	NodeName        string
+	PodName         string
+}
+
+func ParseFlags() (*Configuration, error) {
+	var (
+		argKubeConfigFile  = pflag.String("kubeconfig", "", "Path to kubeconfig file with authorization and master location information. If not set use the inCluster token.")
+		argNamespace       = pflag.String("ds-namespace", "kube-system", "network-pinger deployment namespace")
+		argPodSelector     = pflag.String("pod-selector", "app=network-pinger", "Label selector of the pods probed by the pod mesh check")
+		argInternalDNS     = pflag.String("internal-dns", "kubernetes.default", "check dns from pod")
+		argExternalAddress = pflag.String("external-address", "", "Address probing external egress, ip for icmp or host:port for tcp, empty skips the check")
+		argMTU             = pflag.Int("mtu", 1500, "Expected mtu of the node and pod networks, 0 skips the check")
+		argTimeout         = pflag.Duration("timeout", 2*time.Minute, "Overall timeout of the checklist, checks not started in time are skipped")
+		argProbeTimeout    = pflag.Duration("probe-timeout", 2*time.Second, "Timeout of every single probe")
+		argParallelism     = pflag.Int("parallelism", 16, "Number of targets probed at the same time")
+	)
+
+	klogFlags := flag.NewFlagSet("klog", flag.ExitOnError)
+	klog.InitFlags(klogFlags)
+	pflag.CommandLine.AddGoFlagSet(klogFlags)
+	pflag.Parse()
+
+	config := &Configuration{
+		KubeConfigFile:  *argKubeConfigFile,
+		Namespace:       *argNamespace,
+		PodSelector:     *argPodSelector,
+		InternalDNS:     *argInternalDNS,
+		ExternalAddress: *argExternalAddress,
+		MTU:             *argMTU,
+		Timeout:         *argTimeout,
+		ProbeTimeout:    *argProbeTimeout,
+		Parallelism:     *argParallelism,
+		NodeName:        os.Getenv("NODE_NAME"),
+		PodName:         os.Getenv("POD_NAME"),
+	}
+	if config.Parallelism <= 0 {
+		config.Parallelism = 1
+	}
+	if err := config.initKubeClient(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (config *Configuration) initKubeClient() error {
+	var cfg *rest.Config
+	var err error
+	if config.KubeConfigFile == "" {
+		cfg, err = rest.InClusterConfig()
+		if err != nil {
+			klog.Errorf("use in cluster config failed %v", err)
+			return err
+		}
+	} else {
+		cfg, err = clientcmd.BuildConfigFromFlags("", config.KubeConfigFile)
+		if err != nil {
+			klog.Errorf("use --kubeconfig %s failed %v", config.KubeConfigFile, err)
+			return err
+		}
+	}
+	cfg.Timeout = 15 * time.Second
+	kubeClient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		klog.Errorf("init kubernetes client failed %v", err)
+		return err
+	}
+	config.KubeClient = kubeClient
+
+	networkClient, err := networkClientset.NewForConfig(cfg)
+	if err != nil {
+		klog.Errorf("init network client failed %v", err)
+		return err
+	}
+	config.NetworkClient = networkClient
+	return nil
+}
+
+// inPod tells whether the preflight runs from a pod of a Job, CronJob or DaemonSet
+// rather than from a workstation with a kubeconfig.
+func (config *Configuration) inPod() bool {
+	return config.PodName != ""
+}
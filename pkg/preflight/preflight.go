@@ -0,0 +1,308 @@
+package preflight
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	goping "github.com/prometheus-community/pro-bing"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/klog/v2"
+
+	"github.com/wenwenxiong/network-pinger/pkg/util"
+)
+
+const (
+	StatusPass = "PASS"
+	StatusFail = "FAIL"
+	StatusSkip = "SKIP"
+)
+
+// Result is the outcome of one item of the checklist.
+type Result struct {
+	Name     string
+	Status   string
+	Detail   string
+	Duration time.Duration
+}
+
+type check struct {
+	name string
+	run  func(ctx context.Context, config *Configuration, state *state) (string, error)
+}
+
+// errSkip marks a check that does not apply to this cluster or environment.
+type errSkip struct {
+	reason string
+}
+
+func (e errSkip) Error() string {
+	return e.reason
+}
+
+// state carries what earlier checks found to the later ones.
+type state struct {
+	nodeIPs map[string]string
+}
+
+// checklist is run in order, cheaper and more fundamental checks first.
+var checklist = []check{
+	{"apiserver", checkAPIServer},
+	{"dns", checkDNS},
+	{"node mesh", checkNodeMesh},
+	{"pod mesh", checkPodMesh},
+	{"subnet gateways", checkSubnetGateways},
+	{"external egress", checkExternalEgress},
+	{"mtu", checkMTU},
+}
+
+// Run runs the checklist once within the overall timeout, checks not started in time are skipped.
+func Run(config *Configuration) []Result {
+	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+	defer cancel()
+
+	s := &state{nodeIPs: make(map[string]string)}
+	results := make([]Result, 0, len(checklist))
+	for _, c := range checklist {
+		result := Result{Name: c.name}
+		if ctx.Err() != nil {
+			result.Status, result.Detail = StatusSkip, fmt.Sprintf("overall timeout %v reached", config.Timeout)
+			results = append(results, result)
+			continue
+		}
+		klog.Infof("start preflight check %s", c.name)
+		t1 := time.Now()
+		detail, err := c.run(ctx, config, s)
+		result.Duration = time.Since(t1)
+		switch e := err.(type) {
+		case nil:
+			result.Status, result.Detail = StatusPass, detail
+		case errSkip:
+			result.Status, result.Detail = StatusSkip, e.reason
+		default:
+			klog.Errorf("preflight check %s failed: %v", c.name, err)
+			result.Status, result.Detail = StatusFail, err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Passed is false when any check failed, skipped checks do not fail the preflight.
+func Passed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == StatusFail {
+			return false
+		}
+	}
+	return true
+}
+
+func PrintTable(w io.Writer, config *Configuration, results []Result) {
+	if config.inPod() {
+		fmt.Fprintf(w, "preflight from pod %s on node %s\n\n", config.PodName, config.NodeName)
+	} else {
+		fmt.Fprintf(w, "preflight from outside the cluster, pod network checks may fail\n\n")
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tCHECK\tRESULT\tTIME\tDETAIL")
+	for i, r := range results {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%v\t%s\n", i+1, r.Name, r.Status, r.Duration.Round(time.Millisecond), r.Detail)
+	}
+	_ = tw.Flush()
+	if Passed(results) {
+		fmt.Fprintln(w, "\npreflight passed")
+	} else {
+		fmt.Fprintln(w, "\npreflight failed")
+	}
+}
+
+// checkAPIServer reads /version like the discovery client, through a request bound by ctx.
+func checkAPIServer(ctx context.Context, config *Configuration, _ *state) (string, error) {
+	body, err := config.KubeClient.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Raw()
+	if err != nil {
+		return "", err
+	}
+	var info version.Info
+	if err := json.Unmarshal(body, &info); err != nil {
+		return "", fmt.Errorf("unable to parse the server version: %v", err)
+	}
+	return "kubernetes " + info.GitVersion, nil
+}
+
+func checkDNS(ctx context.Context, config *Configuration, _ *state) (string, error) {
+	if !config.inPod() {
+		return "", errSkip{"cluster dns is only resolvable from a pod"}
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	var r net.Resolver
+	addrs, err := r.LookupHost(ctx, config.InternalDNS)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s resolved to %s", config.InternalDNS, strings.Join(addrs, ",")), nil
+}
+
+func checkNodeMesh(ctx context.Context, config *Configuration, s *state) (string, error) {
+	nodes, err := config.KubeClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+	for _, no := range nodes.Items {
+		for _, addr := range no.Status.Addresses {
+			if addr.Type == v1.NodeInternalIP {
+				s.nodeIPs[no.Name] = addr.Address
+				break
+			}
+		}
+	}
+	if len(s.nodeIPs) == 0 {
+		return "", fmt.Errorf("no node has an internal ip")
+	}
+	return probeAll(ctx, config, "nodes", s.nodeIPs, 0)
+}
+
+func checkPodMesh(ctx context.Context, config *Configuration, _ *state) (string, error) {
+	pods, err := config.KubeClient.CoreV1().Pods(config.Namespace).List(ctx, metav1.ListOptions{LabelSelector: config.PodSelector})
+	if err != nil {
+		return "", err
+	}
+	targets := make(map[string]string)
+	for _, pod := range pods.Items {
+		if pod.Status.PodIP != "" {
+			targets[pod.Namespace+"/"+pod.Name] = pod.Status.PodIP
+		}
+	}
+	if len(targets) == 0 {
+		return "", errSkip{fmt.Sprintf("no running pod matches %s in %s", config.PodSelector, config.Namespace)}
+	}
+	return probeAll(ctx, config, "pods", targets, 0)
+}
+
+func checkSubnetGateways(ctx context.Context, config *Configuration, _ *state) (string, error) {
+	subnets, err := config.NetworkClient.MecV1().Subnets().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+	targets := make(map[string]string)
+	for _, subnet := range subnets.Items {
+		if subnet.Spec.Gateway == "" {
+			continue
+		}
+		// dual stack subnets have one gateway per protocol
+		gateways := strings.Split(subnet.Spec.Gateway, ",")
+		for _, gw := range gateways {
+			gw = strings.TrimSpace(gw)
+			if len(gateways) == 1 {
+				targets[subnet.Name] = gw
+			} else if gw != "" {
+				targets[subnet.Name+"/"+gw] = gw
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return "", errSkip{"no subnet has a gateway"}
+	}
+	return probeAll(ctx, config, "gateways", targets, 0)
+}
+
+func checkExternalEgress(ctx context.Context, config *Configuration, _ *state) (string, error) {
+	if config.ExternalAddress == "" {
+		return "", errSkip{"no --external-address"}
+	}
+	if _, _, err := net.SplitHostPort(config.ExternalAddress); err == nil {
+		t1 := time.Now()
+		dialer := net.Dialer{Timeout: config.ProbeTimeout}
+		conn, err := dialer.DialContext(ctx, "tcp", config.ExternalAddress)
+		if err != nil {
+			return "", err
+		}
+		_ = conn.Close()
+		return fmt.Sprintf("tcp %s connected in %v", config.ExternalAddress, time.Since(t1).Round(time.Millisecond)), nil
+	}
+	return probeAll(ctx, config, "external addresses", map[string]string{config.ExternalAddress: config.ExternalAddress}, 0)
+}
+
+// checkMTU sends packets of the full expected mtu with the do not fragment bit to every node.
+func checkMTU(ctx context.Context, config *Configuration, s *state) (string, error) {
+	if config.MTU <= 0 {
+		return "", errSkip{"mtu check disabled"}
+	}
+	if len(s.nodeIPs) == 0 {
+		return "", errSkip{"no node ip found by the node mesh check"}
+	}
+	detail, err := probeAll(ctx, config, "nodes", s.nodeIPs, config.MTU)
+	if err != nil {
+		return "", fmt.Errorf("packets of mtu %d dropped: %v", config.MTU, err)
+	}
+	return fmt.Sprintf("mtu %d: %s", config.MTU, detail), nil
+}
+
+// probeAll pings every target, mtu sets the packet size with the do not fragment bit when not zero.
+func probeAll(ctx context.Context, config *Configuration, kind string, targets map[string]string, mtu int) (string, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var failed []string
+	sem := make(chan struct{}, config.Parallelism)
+	for name, address := range targets {
+		wg.Add(1)
+		go func(name, address string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if err := probe(ctx, address, mtu, config.ProbeTimeout); err != nil {
+				klog.Warningf("failed to probe %s %s: %v", name, address, err)
+				mu.Lock()
+				failed = append(failed, name)
+				mu.Unlock()
+			}
+		}(name, address)
+	}
+	wg.Wait()
+
+	if len(failed) != 0 {
+		sort.Strings(failed)
+		if len(failed) > 5 {
+			failed = append(failed[:5], fmt.Sprintf("and %d more", len(failed)-5))
+		}
+		return "", fmt.Errorf("%d/%d %s unreachable: %s", len(failed), len(targets), kind, strings.Join(failed, ", "))
+	}
+	return fmt.Sprintf("%d/%d %s reachable", len(targets), len(targets), kind), nil
+}
+
+func probe(ctx context.Context, address string, mtu int, timeout time.Duration) error {
+	pinger, err := goping.NewPinger(address)
+	if err != nil {
+		return err
+	}
+	pinger.SetPrivileged(true)
+	pinger.Count = 3
+	pinger.Interval = 100 * time.Millisecond
+	pinger.Timeout = timeout
+	if mtu > 0 {
+		// ip and icmp headers take 28 bytes on ipv4 and 48 on ipv6
+		headers := 28
+		if util.CheckProtocol(address) == util.ProtocolIPv6 {
+			headers = 48
+		}
+		pinger.Size = mtu - headers
+		pinger.SetDoNotFragment(true)
+	}
+	if err = pinger.RunWithContext(ctx); err != nil {
+		return err
+	}
+	if stats := pinger.Statistics(); stats.PacketsRecv == 0 {
+		return fmt.Errorf("no reply to %d packets", stats.PacketsSent)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package statsd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/wenwenxiong/network-pinger/pkg/util"
+)
+
+const (
+	// FlavorStatsD puts the tags in the metric name the way telegraf reads them,
+	// FlavorDogStatsD appends them after |# like datadog.
+	FlavorStatsD    = "statsd"
+	FlavorDogStatsD = "dogstatsd"
+
+	TypeCounter = "c"
+	TypeGauge   = "g"
+	TypeTiming  = "ms"
+
+	// maxPacket keeps the datagrams below the mtu of most links, a longer line is sent alone.
+	maxPacket = 1432
+)
+
+var (
+	nameSanitizer      = strings.NewReplacer(":", "_", "|", "_", "@", "_", ",", "_", "#", "_", " ", "_", "\n", "_")
+	statsdTagSanitizer = strings.NewReplacer(":", "_", "|", "_", ",", "_", "=", "_", " ", "_", "\n", "_")
+	dogTagSanitizer    = strings.NewReplacer("|", "_", ",", "_", "\n", "_")
+)
+
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// AppendMetric appends one metric line, characters the flavor uses as separators are replaced
+// in names and tags and tags with an empty value are left out.
+func AppendMetric(b []byte, flavor, name string, value float64, metricType string, tags []Tag) []byte {
+	b = append(b, nameSanitizer.Replace(name)...)
+	if flavor == FlavorStatsD {
+		for _, tag := range tags {
+			if tag.Value == "" {
+				continue
+			}
+			b = append(b, ',')
+			b = append(b, statsdTagSanitizer.Replace(tag.Key)...)
+			b = append(b, '=')
+			b = append(b, statsdTagSanitizer.Replace(tag.Value)...)
+		}
+	}
+	b = append(b, ':')
+	b = strconv.AppendFloat(b, value, 'f', -1, 64)
+	b = append(b, '|')
+	b = append(b, metricType...)
+	if flavor == FlavorDogStatsD {
+		sep := "|#"
+		for _, tag := range tags {
+			if tag.Value == "" {
+				continue
+			}
+			b = append(b, sep...)
+			b = append(b, dogTagSanitizer.Replace(tag.Key)...)
+			b = append(b, ':')
+			b = append(b, dogTagSanitizer.Replace(tag.Value)...)
+			sep = ","
+		}
+	}
+	return b
+}
+
+// Client sends metric lines to a statsd server over udp.
+type Client struct {
+	conn net.Conn
+}
+
+func NewClient(address string) (*Client, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("invalid statsd address %q: %v", address, err)
+	}
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn}, nil
+}
+
+// Write packs newline separated lines into as few datagrams as possible and returns how many
+// lines were sent. A send error only shows when the kernel already knows the server is down,
+// lines sent while it restarts are lost.
+func (c *Client) Write(ctx context.Context, lines [][]byte) (int, error) {
+	return util.WriteDatagrams(ctx, c.conn, lines, maxPacket)
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
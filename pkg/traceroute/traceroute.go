@@ -0,0 +1,448 @@
+package traceroute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+
+	"github.com/wenwenxiong/network-pinger/pkg/util"
+)
+
+const (
+	ProtocolICMP = "icmp"
+	ProtocolUDP  = "udp"
+	ProtocolTCP  = "tcp"
+
+	protocolICMPv4 = 1
+	protocolICMPv6 = 58
+)
+
+// Options bound a trace: every round probes at most MaxHops hops and waits at most Timeout for each probe.
+type Options struct {
+	Protocol string
+	Port     int
+	MaxHops  int
+	Rounds   int
+	Timeout  time.Duration
+}
+
+// Hop is what every round saw at one ttl.
+type Hop struct {
+	TTL      int
+	Address  string
+	Sent     int
+	Lost     int
+	AvgRTT   time.Duration
+	BestRTT  time.Duration
+	WorstRTT time.Duration
+}
+
+type Result struct {
+	Target   string
+	Protocol string
+	Reached  bool
+	Hops     []Hop
+	Time     time.Time
+}
+
+// LastHop returns the farthest hop that answered, nil if no hop answered.
+func (r *Result) LastHop() *Hop {
+	for i := len(r.Hops) - 1; i >= 0; i-- {
+		if r.Hops[i].Address != "" {
+			return &r.Hops[i]
+		}
+	}
+	return nil
+}
+
+// String formats the hops the way mtr reports them.
+func (r *Result) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s traceroute to %s, reached %v", r.Protocol, r.Target, r.Reached)
+	for _, hop := range r.Hops {
+		address := hop.Address
+		if address == "" {
+			address = "???"
+		}
+		fmt.Fprintf(&b, "\n%3d. %-39s loss %5.1f%% sent %d avg %.2fms best %.2fms worst %.2fms",
+			hop.TTL, address, float64(hop.Lost)*100/float64(hop.Sent), hop.Sent,
+			ms(hop.AvgRTT), ms(hop.BestRTT), ms(hop.WorstRTT))
+	}
+	return b.String()
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Run traces the path to the target, every round sends one probe per ttl until the target answers
+// or the context is canceled.
+func Run(ctx context.Context, target string, opts Options) (*Result, error) {
+	ip := net.ParseIP(target)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip %q", target)
+	}
+	switch opts.Protocol {
+	case ProtocolICMP, ProtocolUDP, ProtocolTCP:
+	default:
+		return nil, fmt.Errorf("unsupported traceroute protocol %q", opts.Protocol)
+	}
+	if opts.MaxHops <= 0 || opts.Rounds <= 0 || opts.Timeout <= 0 {
+		return nil, fmt.Errorf("max hops, rounds and timeout of a traceroute must be positive")
+	}
+
+	t, err := newTracer(ctx, ip, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer t.close()
+
+	result := &Result{Target: target, Protocol: opts.Protocol, Time: time.Now()}
+	hops := make([]Hop, opts.MaxHops)
+	total := make([]time.Duration, opts.MaxHops)
+	maxHops := opts.MaxHops
+	for round := 0; round < opts.Rounds; round++ {
+		for ttl := 1; ttl <= maxHops; ttl++ {
+			hop := &hops[ttl-1]
+			hop.TTL = ttl
+			hop.Sent++
+			peer, rtt, reached, err := t.probe(ttl)
+			if err != nil {
+				return nil, err
+			}
+			if err = ctx.Err(); err != nil {
+				return nil, err
+			}
+			if peer == nil {
+				hop.Lost++
+				continue
+			}
+			hop.Address = peer.String()
+			total[ttl-1] += rtt
+			if hop.BestRTT == 0 || rtt < hop.BestRTT {
+				hop.BestRTT = rtt
+			}
+			if rtt > hop.WorstRTT {
+				hop.WorstRTT = rtt
+			}
+			if reached {
+				result.Reached = true
+				maxHops = ttl
+				break
+			}
+		}
+	}
+	for i := 0; i < maxHops; i++ {
+		if received := hops[i].Sent - hops[i].Lost; received != 0 {
+			hops[i].AvgRTT = total[i] / time.Duration(received)
+		}
+	}
+	result.Hops = hops[:maxHops]
+	return result, nil
+}
+
+const (
+	replyEcho = iota
+	replyTimeExceeded
+	replyUnreachable
+)
+
+// reply is an icmp message together with the header fields it quotes from the probe.
+type reply struct {
+	peer    net.IP
+	at      time.Time
+	kind    int
+	id      int
+	seq     int
+	dst     net.IP
+	srcPort int
+}
+
+type tracer struct {
+	ctx     context.Context
+	target  net.IP
+	ipv6    bool
+	opts    Options
+	id      int
+	seq     int
+	conn    *icmp.PacketConn
+	replies chan reply
+}
+
+func newTracer(ctx context.Context, target net.IP, opts Options) (*tracer, error) {
+	t := &tracer{
+		ctx:     ctx,
+		target:  target,
+		ipv6:    target.To4() == nil,
+		opts:    opts,
+		id:      util.NextICMPID(),
+		replies: make(chan reply, 64),
+	}
+	var err error
+	if t.ipv6 {
+		t.conn, err = icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	} else {
+		t.conn, err = icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	}
+	if err != nil {
+		return nil, err
+	}
+	go t.read()
+	return t, nil
+}
+
+func (t *tracer) close() {
+	_ = t.conn.Close()
+}
+
+// read parses every icmp message the host receives until the connection is closed.
+func (t *tracer) read() {
+	defer close(t.replies)
+	proto := protocolICMPv4
+	if t.ipv6 {
+		proto = protocolICMPv6
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := t.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil {
+			continue
+		}
+		r := reply{peer: net.ParseIP(strings.Split(peer.String(), "%")[0]), at: time.Now()}
+		var quoted []byte
+		switch body := msg.Body.(type) {
+		case *icmp.Echo:
+			if msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply {
+				continue
+			}
+			r.kind, r.id, r.seq = replyEcho, body.ID, body.Seq
+		case *icmp.TimeExceeded:
+			r.kind, quoted = replyTimeExceeded, body.Data
+		case *icmp.DstUnreach:
+			r.kind, quoted = replyUnreachable, body.Data
+		default:
+			continue
+		}
+		if quoted != nil && !t.parseQuoted(&r, quoted) {
+			continue
+		}
+		select {
+		case t.replies <- r:
+		default:
+		}
+	}
+}
+
+// parseQuoted reads the destination and the first bytes of the probe quoted by an icmp error.
+func (t *tracer) parseQuoted(r *reply, data []byte) bool {
+	var payload []byte
+	var proto int
+	if t.ipv6 {
+		if len(data) < 48 {
+			return false
+		}
+		proto, r.dst, payload = int(data[6]), net.IP(data[24:40]), data[40:]
+	} else {
+		if len(data) < 20 {
+			return false
+		}
+		ihl := int(data[0]&0x0f) * 4
+		if len(data) < ihl+8 {
+			return false
+		}
+		proto, r.dst, payload = int(data[9]), net.IP(data[16:20]), data[ihl:]
+	}
+	switch proto {
+	case protocolICMPv4, protocolICMPv6:
+		r.id = int(payload[4])<<8 | int(payload[5])
+		r.seq = int(payload[6])<<8 | int(payload[7])
+	case syscall.IPPROTO_UDP, syscall.IPPROTO_TCP:
+		r.srcPort = int(payload[0])<<8 | int(payload[1])
+	default:
+		return false
+	}
+	return true
+}
+
+// probe sends one probe with the ttl, peer is nil when nothing answered in time.
+func (t *tracer) probe(ttl int) (net.IP, time.Duration, bool, error) {
+	t.seq = (t.seq + 1) & 0xffff
+	switch t.opts.Protocol {
+	case ProtocolUDP:
+		return t.probeUDP(ttl)
+	case ProtocolTCP:
+		return t.probeTCP(ttl)
+	default:
+		return t.probeICMP(ttl)
+	}
+}
+
+func (t *tracer) probeICMP(ttl int) (net.IP, time.Duration, bool, error) {
+	msg := icmp.Message{Code: 0, Body: &icmp.Echo{ID: t.id, Seq: t.seq, Data: []byte("network-pinger")}}
+	if t.ipv6 {
+		msg.Type = ipv6.ICMPTypeEchoRequest
+		if err := t.conn.IPv6PacketConn().SetHopLimit(ttl); err != nil {
+			return nil, 0, false, err
+		}
+	} else {
+		msg.Type = ipv4.ICMPTypeEcho
+		if err := t.conn.IPv4PacketConn().SetTTL(ttl); err != nil {
+			return nil, 0, false, err
+		}
+	}
+	data, err := msg.Marshal(nil)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	start := time.Now()
+	if _, err = t.conn.WriteTo(data, &net.IPAddr{IP: t.target}); err != nil {
+		return nil, 0, false, err
+	}
+	peer, at, reached := t.wait(start, nil, func(r reply) bool {
+		// echo replies come from the target itself, errors quote the probe sent to the target
+		if r.kind == replyEcho {
+			return r.id == t.id && r.seq == t.seq && r.peer.Equal(t.target)
+		}
+		return r.id == t.id && r.seq == t.seq && r.dst.Equal(t.target)
+	})
+	return peer, at.Sub(start), reached, nil
+}
+
+func (t *tracer) probeUDP(ttl int) (net.IP, time.Duration, bool, error) {
+	network := "udp4"
+	if t.ipv6 {
+		network = "udp6"
+	}
+	conn, err := net.ListenPacket(network, ":0")
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer conn.Close()
+	if t.ipv6 {
+		err = ipv6.NewPacketConn(conn).SetHopLimit(ttl)
+	} else {
+		err = ipv4.NewPacketConn(conn).SetTTL(ttl)
+	}
+	if err != nil {
+		return nil, 0, false, err
+	}
+	srcPort := conn.LocalAddr().(*net.UDPAddr).Port
+
+	// like the classic traceroute, every ttl goes to its own port so that probes are told apart on the wire
+	start := time.Now()
+	if _, err = conn.WriteTo([]byte("network-pinger"), &net.UDPAddr{IP: t.target, Port: t.opts.Port + ttl - 1}); err != nil {
+		return nil, 0, false, err
+	}
+	peer, at, reached := t.wait(start, nil, func(r reply) bool {
+		return r.kind != replyEcho && r.srcPort == srcPort && r.dst.Equal(t.target)
+	})
+	return peer, at.Sub(start), reached, nil
+}
+
+func (t *tracer) probeTCP(ttl int) (net.IP, time.Duration, bool, error) {
+	srcPort := make(chan int, 1)
+	dialer := net.Dialer{
+		Timeout: t.opts.Timeout,
+		Control: func(_, _ string, c syscall.RawConn) error {
+			var sockErr error
+			err := c.Control(func(fd uintptr) {
+				if t.ipv6 {
+					sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, ttl)
+				} else {
+					sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TTL, ttl)
+				}
+				if sockErr != nil {
+					return
+				}
+				// bind now to learn the source port quoted by the icmp errors
+				var sa syscall.Sockaddr = &syscall.SockaddrInet4{}
+				if t.ipv6 {
+					sa = &syscall.SockaddrInet6{}
+				}
+				if sockErr = syscall.Bind(int(fd), sa); sockErr != nil {
+					return
+				}
+				local, err := syscall.Getsockname(int(fd))
+				if err != nil {
+					sockErr = err
+					return
+				}
+				switch sa := local.(type) {
+				case *syscall.SockaddrInet4:
+					srcPort <- sa.Port
+				case *syscall.SockaddrInet6:
+					srcPort <- sa.Port
+				}
+			})
+			if err != nil {
+				return err
+			}
+			return sockErr
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(t.ctx, t.opts.Timeout)
+	defer cancel()
+	start := time.Now()
+	connected := make(chan time.Time, 1)
+	go func() {
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(t.target.String(), fmt.Sprint(t.opts.Port)))
+		// a reset also means the syn made it to the target
+		if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+			connected <- time.Now()
+		}
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}()
+
+	var port int
+	select {
+	case port = <-srcPort:
+	case at := <-connected:
+		return t.target, at.Sub(start), true, nil
+	case <-ctx.Done():
+		return nil, 0, false, nil
+	}
+	peer, at, reached := t.wait(start, connected, func(r reply) bool {
+		return r.kind != replyEcho && r.srcPort == port && r.dst.Equal(t.target)
+	})
+	return peer, at.Sub(start), reached, nil
+}
+
+// wait returns the first reply accepted by match, or the connection of a tcp probe.
+func (t *tracer) wait(start time.Time, connected <-chan time.Time, match func(reply) bool) (net.IP, time.Time, bool) {
+	timer := time.NewTimer(time.Until(start.Add(t.opts.Timeout)))
+	defer timer.Stop()
+	for {
+		select {
+		case r, ok := <-t.replies:
+			if !ok {
+				return nil, start, false
+			}
+			if !match(r) {
+				continue
+			}
+			reached := r.kind == replyEcho || (r.kind == replyUnreachable && r.peer.Equal(t.target))
+			return r.peer, r.at, reached
+		case at := <-connected:
+			return t.target, at, true
+		case <-timer.C:
+			return nil, start, false
+		case <-t.ctx.Done():
+			return nil, start, false
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	procNetArp = "/proc/net/arp"
+	// arpFlagComplete is ATF_COM, set once the neighbour answered.
+	arpFlagComplete = 0x2
+)
+
+// IsOnLink checks whether the ip is reachable without a gateway from one of the local interfaces.
+func IsOnLink(ipStr string) bool {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return false
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ArpResolve triggers neighbour resolution for an on-link IPv4 address and
+// returns the hardware address the kernel learned for it.
+func ArpResolve(ipStr string, timeout time.Duration) (string, error) {
+	if CheckProtocol(ipStr) != ProtocolIPv4 {
+		return "", fmt.Errorf("%s is not an IPv4 address", ipStr)
+	}
+
+	// any outgoing datagram makes the kernel resolve the neighbour, no privilege needed
+	conn, err := net.DialTimeout("udp4", net.JoinHostPort(ipStr, "9"), timeout)
+	if err != nil {
+		return "", err
+	}
+	_, _ = conn.Write([]byte{0})
+	_ = conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		mac, err := lookupArpEntry(ipStr)
+		if err != nil {
+			return "", err
+		}
+		if mac != "" {
+			return mac, nil
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("no arp reply from %s in %v", ipStr, timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func lookupArpEntry(ipStr string) (string, error) {
+	f, err := os.Open(procNetArp)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	// skip the header line
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[0] != ipStr {
+			continue
+		}
+		var flags int
+		if _, err := fmt.Sscanf(fields[2], "0x%x", &flags); err != nil {
+			continue
+		}
+		if flags&arpFlagComplete != 0 {
+			return fields[3], nil
+		}
+	}
+	return "", scanner.Err()
+}
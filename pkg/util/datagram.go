@@ -0,0 +1,38 @@
+package util
+
+import (
+	"context"
+	"net"
+)
+
+// WriteDatagrams packs newline separated lines into datagrams of at most size bytes, a longer
+// line is sent alone. It returns how many lines were sent before a write failed, so the caller
+// retries only the others.
+func WriteDatagrams(ctx context.Context, conn net.Conn, lines [][]byte, size int) (int, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetWriteDeadline(deadline); err != nil {
+			return 0, err
+		}
+	}
+	sent := 0
+	packet := make([]byte, 0, size)
+	for i, line := range lines {
+		if len(packet) != 0 && len(packet)+1+len(line) > size {
+			if _, err := conn.Write(packet); err != nil {
+				return sent, err
+			}
+			sent = i
+			packet = packet[:0]
+		}
+		if len(packet) != 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) != 0 {
+		if _, err := conn.Write(packet); err != nil {
+			return sent, err
+		}
+	}
+	return len(lines), nil
+}
@@ -0,0 +1,15 @@
+package util
+
+import (
+	"os"
+	"sync/atomic"
+)
+
+// icmpID starts at the pid like ping, every echo session takes the next value so the echo,
+// traceroute and path mtu probes running at the same time never share an identifier.
+var icmpID = uint32(os.Getpid())
+
+// NextICMPID returns the identifier of a new icmp echo session.
+func NextICMPID() int {
+	return int(atomic.AddUint32(&icmpID, 1) & 0xffff)
+}
@@ -1,7 +1,10 @@
 package util
 
 import (
+	"bytes"
+	"math/big"
 	"net"
+	"sort"
 	"strings"
 )
 
@@ -35,4 +38,135 @@ func CheckProtocol(address string) string {
 
 	// cidr formal error
 	return ""
-}
\ No newline at end of file
+}
+
+// CIDRContainIP checks whether the ip belongs to one of the comma separated cidrs.
+func CIDRContainIP(cidrStr, ipStr string) bool {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range strings.Split(cidrStr, ",") {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			continue
+		}
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsIPInRanges checks whether the ip is covered by excludeIps style entries,
+// which are either a single address or an inclusive "start..end" range.
+func ContainsIPInRanges(ranges []string, ipStr string) bool {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return false
+	}
+	for _, r := range ranges {
+		parts := strings.Split(r, "..")
+		start := net.ParseIP(strings.TrimSpace(parts[0]))
+		end := start
+		if len(parts) == 2 {
+			end = net.ParseIP(strings.TrimSpace(parts[1]))
+		}
+		if start == nil || end == nil {
+			continue
+		}
+		if bytes.Compare(ip.To16(), start.To16()) >= 0 && bytes.Compare(ip.To16(), end.To16()) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// UsableIPCount returns the number of assignable addresses of the cidr, excluding the
+// network and broadcast addresses for IPv4, the addresses covered by excludeIps and the gateway.
+func UsableIPCount(cidr, gateway string, excludeIps []string) *big.Int {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return big.NewInt(0)
+	}
+	first, last := ipNetRange(ipNet)
+	if ipNet.IP.To4() != nil {
+		ones, bits := ipNet.Mask.Size()
+		if bits-ones >= 2 {
+			first.Add(first, big.NewInt(1))
+			last.Sub(last, big.NewInt(1))
+		}
+	}
+	if first.Cmp(last) > 0 {
+		return big.NewInt(0)
+	}
+
+	// clip the excluded ranges to the cidr and merge overlaps before subtracting
+	type interval struct{ start, end *big.Int }
+	var excluded []interval
+	for _, r := range excludeIps {
+		parts := strings.Split(r, "..")
+		start := net.ParseIP(strings.TrimSpace(parts[0]))
+		end := start
+		if len(parts) == 2 {
+			end = net.ParseIP(strings.TrimSpace(parts[1]))
+		}
+		if start == nil || end == nil || (start.To4() == nil) != (ipNet.IP.To4() == nil) {
+			continue
+		}
+		s, e := ipToBigInt(start), ipToBigInt(end)
+		if s.Cmp(first) < 0 {
+			s = new(big.Int).Set(first)
+		}
+		if e.Cmp(last) > 0 {
+			e = new(big.Int).Set(last)
+		}
+		if s.Cmp(e) <= 0 {
+			excluded = append(excluded, interval{s, e})
+		}
+	}
+	if gw := net.ParseIP(gateway); gw != nil && ipNet.Contains(gw) {
+		g := ipToBigInt(gw)
+		if g.Cmp(first) >= 0 && g.Cmp(last) <= 0 {
+			excluded = append(excluded, interval{g, new(big.Int).Set(g)})
+		}
+	}
+	sort.Slice(excluded, func(i, j int) bool { return excluded[i].start.Cmp(excluded[j].start) < 0 })
+
+	count := new(big.Int).Sub(last, first)
+	count.Add(count, big.NewInt(1))
+	var merged *interval
+	one := big.NewInt(1)
+	for i := range excluded {
+		cur := excluded[i]
+		if merged != nil && cur.start.Cmp(new(big.Int).Add(merged.end, one)) <= 0 {
+			if cur.end.Cmp(merged.end) > 0 {
+				merged.end = cur.end
+			}
+			continue
+		}
+		if merged != nil {
+			count.Sub(count, new(big.Int).Add(new(big.Int).Sub(merged.end, merged.start), one))
+		}
+		merged = &interval{cur.start, cur.end}
+	}
+	if merged != nil {
+		count.Sub(count, new(big.Int).Add(new(big.Int).Sub(merged.end, merged.start), one))
+	}
+	return count
+}
+
+func ipNetRange(ipNet *net.IPNet) (*big.Int, *big.Int) {
+	first := ipToBigInt(ipNet.IP)
+	ones, bits := ipNet.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	last := new(big.Int).Add(first, size)
+	return first, last.Sub(last, big.NewInt(1))
+}
+
+func ipToBigInt(ip net.IP) *big.Int {
+	if v4 := ip.To4(); v4 != nil {
+		return new(big.Int).SetBytes(v4)
+	}
+	return new(big.Int).SetBytes(ip.To16())
+}